@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"doccer/metrics"
+	mux "github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// accessEntry collects what the handlers learn about a request for its
+// access log line.
+type accessEntry struct {
+	route string
+	user  string
+}
+
+type accessEntryKey struct{}
+
+// accessRecorder keeps the status and the internal error of a response.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	err    error
+}
+
+func (w *accessRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *accessRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// withAccessLog logs and counts every request with its route, status,
+// latency and user, and puts the status on the request span. Failed
+// requests are logged as errors with their cause.
+func (a *Api) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		rec := &accessRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+		latency := time.Since(start)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", entry.route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", latency),
+		}
+		if entry.user != "" {
+			attrs = append(attrs, slog.String("user", entry.user))
+		}
+		level := slog.LevelInfo
+		if probeRoutes[entry.route] {
+			level = slog.LevelDebug
+		}
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+			if rec.err != nil {
+				attrs = append(attrs, slog.String("error", rec.err.Error()))
+			}
+		}
+		a.log.LogAttrs(r.Context(), level, "request", attrs...)
+
+		route := entry.route
+		if route == "" {
+			// unmatched paths would make a label value each
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(r.Method, route, rec.status, latency)
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if entry.user != "" {
+			span.SetAttributes(semconv.EnduserID(entry.user))
+		}
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+			if rec.err != nil {
+				span.RecordError(rec.err)
+			}
+		}
+	})
+}
+
+// recordRoute is a router middleware, as only the router knows the route
+// template of a request.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessEntryKey{}).(*accessEntry); ok {
+			if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				entry.route = template
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(semconv.HTTPRoute(template))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func recordUser(r *http.Request, user string) {
+	if entry, ok := r.Context().Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.user = user
+	}
+}
+
+// recordError keeps an internal error for the access log, which the
+// client is not shown.
+func recordError(w http.ResponseWriter, err error) {
+	if rec, ok := w.(*accessRecorder); ok {
+		rec.err = err
+	}
+}
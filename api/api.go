@@ -2,24 +2,54 @@ package api
 
 import (
 	"context"
+	"doccer/config"
 	"doccer/data"
+	"doccer/metrics"
 	"doccer/model"
+	"doccer/ratelimit"
 	"encoding/json"
 	mux "github.com/gorilla/mux"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
 )
 
 type Api struct {
 	useCases model.UseCasesInterface
+	log *slog.Logger
+	writeLimiter *ratelimit.Limiter
+	lintLimiter *ratelimit.Limiter
+	trustProxy bool
+	validator *model.DocValidator
 }
-func NewApi(x model.UseCasesInterface) *Api {
+// NewApi serves the use cases. Without a validator request bodies get the
+// default size limit.
+func NewApi(x model.UseCasesInterface, log *slog.Logger, limits config.RateLimitConfig, validator *model.DocValidator) *Api {
+	if validator == nil {
+		validator = model.NewDocValidator(config.Default().Limits, nil)
+	}
 	return &Api{
 		useCases: x,
+		log: log,
+		writeLimiter: ratelimit.New(limits.Writes),
+		lintLimiter: ratelimit.New(limits.Lint),
+		trustProxy: limits.TrustProxy,
+		validator: validator,
 	}
 }
 
 func (a *Api) Router() http.Handler {
+	return withRequestId(withTracing(a.withAccessLog(a.router())))
+}
+
+func (a *Api) router() *mux.Router {
 	router := mux.NewRouter()
+	router.Use(recordRoute, a.limitBody, a.authenticate, a.rateLimit)
+	router.HandleFunc("/openapi.json", a.getSpec).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", a.healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", a.readyz).Methods(http.MethodGet)
 	router.HandleFunc("/register", a.register).Methods(http.MethodPost)
 	router.HandleFunc("/login", a.login).Methods(http.MethodPost)
 	router.HandleFunc("/logout", a.auth(a.logout, true)).Methods(http.MethodPost)
@@ -30,94 +60,182 @@ func (a *Api) Router() http.Handler {
 	router.HandleFunc("/docs/{doc_id}", a.auth(a.editDoc, true)).Methods(http.MethodPut)
 
 	router.HandleFunc("/docs/{doc_id}/access", a.auth(a.changeDocAccess, true)).Methods(http.MethodPost)
+	router.HandleFunc("/docs/{doc_id}/access", a.auth(a.getDocAccess, true)).Methods(http.MethodGet)
+	router.HandleFunc("/docs/{doc_id}/access", a.auth(a.replaceDocAccess, true)).Methods(http.MethodPut)
+	router.HandleFunc("/docs/{doc_id}/access/explain", a.auth(a.explainDocAccess, true)).Methods(http.MethodGet)
+	router.HandleFunc("/docs/{doc_id}/access/members/{user_id}", a.auth(a.revokeMemberAccess, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/docs/{doc_id}/access/groups/{group_id}", a.auth(a.revokeGroupAccess, true)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/docs/{doc_id}/folder", a.auth(a.moveDoc, true)).Methods(http.MethodPut)
 
+	router.HandleFunc("/docs/{doc_id}/outline", a.auth(a.getDocOutline, true)).Methods(http.MethodGet)
 	router.HandleFunc("/docs/{doc_id}/linter", a.auth(a.launchLinter, true)).Methods(http.MethodGet)
 
 	router.HandleFunc("/docs", a.auth(a.getAllDocs, true)).Methods(http.MethodGet)
+	router.HandleFunc("/search", a.auth(a.search, true)).Methods(http.MethodGet)
+	router.HandleFunc("/symbols", a.auth(a.findSymbols, true)).Methods(http.MethodGet)
 
 	router.HandleFunc("/users", a.auth(a.editUser, true)).Methods(http.MethodPut)
 	router.HandleFunc("/users", a.auth(a.getUser, true)).Methods(http.MethodGet)
 
 	router.HandleFunc("/users/groups", a.auth(a.createGroup, true)).Methods(http.MethodPost)
+	router.HandleFunc("/users/groups/{group_id}", a.auth(a.deleteGroup, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/users/groups/{group_id}", a.auth(a.editGroup, true)).Methods(http.MethodPut)
+	// deprecated, the group id is taken from the body
 	router.HandleFunc("/users/groups", a.auth(a.deleteGroup, true)).Methods(http.MethodDelete)
 	router.HandleFunc("/users/groups", a.auth(a.editGroup, true)).Methods(http.MethodPut)
 
 	router.HandleFunc("/users/groups/{group_id}/members", a.auth(a.getMembers, true)).Methods(http.MethodGet)
 	router.HandleFunc("/users/groups/{group_id}/members", a.auth(a.removeMember, true)).Methods(http.MethodDelete)
 	router.HandleFunc("/users/groups/{group_id}/members", a.auth(a.addMember, true)).Methods(http.MethodPut)
+	router.HandleFunc("/users/groups/{group_id}/roles", a.auth(a.setMemberRole, true)).Methods(http.MethodPut)
+	router.HandleFunc("/users/groups/{group_id}/owner", a.auth(a.transferGroupOwnership, true)).Methods(http.MethodPut)
+
+	router.HandleFunc("/users/groups/{group_id}/subgroups", a.auth(a.getSubgroups, true)).Methods(http.MethodGet)
+	router.HandleFunc("/users/groups/{group_id}/subgroups", a.auth(a.removeSubgroup, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/users/groups/{group_id}/subgroups", a.auth(a.addSubgroup, true)).Methods(http.MethodPut)
+
+	router.HandleFunc("/folders", a.auth(a.createFolder, true)).Methods(http.MethodPost)
+	router.HandleFunc("/folders", a.auth(a.getUserFolders, true)).Methods(http.MethodGet)
+	router.HandleFunc("/folders/{folder_id}", a.auth(a.getFolder, true)).Methods(http.MethodGet)
+	router.HandleFunc("/folders/{folder_id}", a.auth(a.renameFolder, true)).Methods(http.MethodPut)
+	router.HandleFunc("/folders/{folder_id}", a.auth(a.deleteFolder, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/folders/{folder_id}/folders", a.auth(a.getSubfolders, true)).Methods(http.MethodGet)
+	router.HandleFunc("/folders/{folder_id}/parent", a.auth(a.moveFolder, true)).Methods(http.MethodPut)
+
+	router.HandleFunc("/folders/{folder_id}/access", a.auth(a.changeFolderAccess, true)).Methods(http.MethodPost)
+	router.HandleFunc("/folders/{folder_id}/access", a.auth(a.getFolderAccess, true)).Methods(http.MethodGet)
+	router.HandleFunc("/folders/{folder_id}/access/members/{user_id}", a.auth(a.revokeFolderMemberAccess, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/folders/{folder_id}/access/groups/{group_id}", a.auth(a.revokeFolderGroupAccess, true)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/orgs", a.auth(a.createOrg, true)).Methods(http.MethodPost)
+	router.HandleFunc("/orgs", a.auth(a.getUserOrgs, true)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{org_id}", a.auth(a.getOrg, true)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{org_id}", a.auth(a.editOrg, true)).Methods(http.MethodPut)
+	router.HandleFunc("/orgs/{org_id}", a.auth(a.deleteOrg, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/orgs/{org_id}/docs", a.auth(a.getOrgDocs, true)).Methods(http.MethodGet)
+
+	router.HandleFunc("/orgs/{org_id}/members", a.auth(a.getOrgMembers, true)).Methods(http.MethodGet)
+	router.HandleFunc("/orgs/{org_id}/members", a.auth(a.removeOrgMember, true)).Methods(http.MethodDelete)
+	router.HandleFunc("/orgs/{org_id}/members", a.auth(a.addOrgMember, true)).Methods(http.MethodPut)
+	router.HandleFunc("/orgs/{org_id}/roles", a.auth(a.setOrgMemberRole, true)).Methods(http.MethodPut)
+
+	router.HandleFunc("/debug/goroutines", a.admin(a.getGoroutines)).Methods(http.MethodGet)
+	router.HandleFunc("/debug/lint-jobs", a.admin(a.getLintJobs)).Methods(http.MethodGet)
+	router.HandleFunc("/debug/pprof/cmdline", a.admin(pprof.Cmdline))
+	router.HandleFunc("/debug/pprof/profile", a.admin(longWrite(pprof.Profile)))
+	router.HandleFunc("/debug/pprof/symbol", a.admin(pprof.Symbol))
+	router.HandleFunc("/debug/pprof/trace", a.admin(longWrite(pprof.Trace)))
+	router.PathPrefix("/debug/pprof/").HandlerFunc(a.admin(longWrite(pprof.Index)))
 
 	return router
 }
 
-func (a *Api) register(w http.ResponseWriter, r *http.Request) {
-	var m model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	user, err := a.useCases.Register(m)
+func (a *Api) writeJson(w http.ResponseWriter, v interface{}) {
+	respJson, err := json.Marshal(v)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		if err == model.ErrAlreadyExists {
-			_, _ = w.Write([]byte("login already exists"))
-		}
+		a.writeError(w, err)
 		return
 	}
-	respJson, err := json.Marshal(user)
-	_, err = w.Write(respJson)
-	if err != nil {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	if _, err := w.Write(respJson); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		return
 	}
 }
 
-func (a *Api) login(w http.ResponseWriter, r *http.Request) {
+// pageRequest reads the cursor, limit and view query parameters of a
+// listing. view=summary leaves doc texts out.
+func pageRequest(r *http.Request) (model.PageRequest, error) {
+	query := r.URL.Query()
+	page := model.PageRequest{
+		Cursor:  query.Get("cursor"),
+		Summary: query.Get("view") == "summary",
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return page, model.ValidationError(model.FieldError{Field: "limit", Message: "must be an integer"})
+		}
+		page.Limit = n
+	}
+	return page, nil
+}
+
+func (a *Api) writePage(w http.ResponseWriter, items interface{}, next string) {
+	a.writeJson(w, data.Page{Items: items, Next: next})
+}
+
+func (a *Api) register(w http.ResponseWriter, r *http.Request) {
 	var m model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
-
-	loginResponse, err := a.useCases.Login(m)
+	user, err := a.useCases.Register(r.Context(), m)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		a.writeError(w, err)
 		return
 	}
+	a.writeJson(w, user)
+}
 
-	w.Header().Set("Content-Type", "application/jwt")
-	respJson, err := json.Marshal(loginResponse)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) login(w http.ResponseWriter, r *http.Request) {
+	var m model.LoginRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	loginResponse, err := a.useCases.Login(r.Context(), m)
+	if err != nil {
+		a.writeError(w, err)
 		return
 	}
+
+	a.writeJson(w, loginResponse)
 }
 
-func (a *Api) auth(f func (w http.ResponseWriter, r *http.Request), isRequired bool) func (w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
+type invalidTokenKey struct{}
+
+// authenticate resolves the AuthToken of every request before the rate
+// limiter and the handler see it; auth decides whether the handler needs
+// one.
+func (a *Api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("AuthToken")
 		if token == "" {
-			if isRequired {
-				w.WriteHeader(http.StatusUnauthorized)
-				_, _ = w.Write([]byte("Missing authorization token"))
-			}
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		userId, err := a.useCases.Auth(token)
+		userId, err := a.useCases.Auth(r.Context(), token)
 		if err != nil {
-			if isRequired {
-				w.WriteHeader(http.StatusUnauthorized)
-				_, _ = w.Write([]byte("Wrong authorization token"))
-			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), invalidTokenKey{}, true)))
 			return
 		}
+		recordUser(r, *userId)
 		ctx := context.WithValue(r.Context(), "myUserId", *userId)
-		f(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *Api) auth(f func (w http.ResponseWriter, r *http.Request), isRequired bool) func (w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// routes open to anonymous users serve requests with a bad token
+		// anonymously, as they did before tokens were checked up front
+		if isRequired && r.Context().Value(invalidTokenKey{}) != nil {
+			a.writeError(w, model.ErrUnauthorized)
+			return
+		}
+		if isRequired && r.Context().Value("myUserId") == nil {
+			metrics.AuthFailed(metrics.AuthMissingToken)
+			a.writeError(w, model.ErrUnauthorized)
+			return
+		}
+		f(w, r)
 	}
 }
 
@@ -126,8 +244,7 @@ func (a *Api) logout(w http.ResponseWriter, r *http.Request) {
 	if myId == nil {
 		return
 	}
-	println("Logout request by user ", myId)
-	w.WriteHeader(http.StatusNotImplemented)
+	a.writeError(w, model.ErrNotImplemented)
 }
 
 func (a *Api) getDoc(w http.ResponseWriter, r *http.Request) {
@@ -135,208 +252,312 @@ func (a *Api) getDoc(w http.ResponseWriter, r *http.Request) {
 	id := data.Id(mux.Vars(r)["doc_id"])
 	var newDoc *data.Doc
 	if myId != nil {
-		doc, err := a.useCases.GetDoc(data.Id(myId.(string)), id)
+		doc, err := a.useCases.GetDoc(r.Context(), data.Id(myId.(string)), id)
 		newDoc = doc
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			a.writeError(w, err)
 			return
 		}
-		println("Get doc request with id", id, "by user", myId)
 	} else {
-		doc, err := a.useCases.GetDoc("-1", id)
+		doc, err := a.useCases.GetDoc(r.Context(), model.AnonymousId, id)
 		newDoc = doc
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			a.writeError(w, err)
 			return
 		}
-		println("Get doc request with id", id, "by unauthorized user")
-	}
-	respJson, err := json.Marshal(newDoc)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
 	}
+	a.writeJson(w, newDoc)
 }
 
 func (a *Api) createDoc(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	var m data.Doc
 	var newDoc *data.Doc
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
 	if myId != nil {
-		doc, err := a.useCases.CreateDoc(data.Id(myId.(string)), m)
+		doc, err := a.useCases.CreateDoc(r.Context(), data.Id(myId.(string)), m)
 		newDoc = doc
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			a.writeError(w, err)
 			return
 		}
-		println("Create doc request by user", myId)
 	} else {
-		doc, err := a.useCases.CreateDoc("-1", m)
+		doc, err := a.useCases.CreateDoc(r.Context(), model.AnonymousId, m)
 		newDoc = doc
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			a.writeError(w, err)
 			return
 		}
-		println("Create doc request by unauthorized user")
 	}
-	respJson, err := json.Marshal(newDoc)
+	a.writeJson(w, newDoc)
+}
+
+func (a *Api) deleteDoc(w http.ResponseWriter, r *http.Request) {
+	id := data.Id(mux.Vars(r)["doc_id"])
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	err := a.useCases.DeleteDoc(r.Context(), data.Id(myId.(string)), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
+}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) editDoc(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["doc_id"]
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
+	var m model.DocEdit
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.Id = data.Id(id)
+	doc, err := a.useCases.EditDoc(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, doc)
 }
 
-func (a *Api) deleteDoc(w http.ResponseWriter, r *http.Request) {
-	id := data.Id(mux.Vars(r)["id"])
+func (a *Api) launchLinter(w http.ResponseWriter, r *http.Request) {
+	doc_id := mux.Vars(r)["doc_id"]
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
-	err := a.useCases.DeleteDoc(data.Id(myId.(string)), id)
+	err := a.useCases.LaunchLinter(r.Context(), data.Id(myId.(string)), data.Id(doc_id))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Delete doc request with id", id, "by user", myId)
 }
 
-func (a *Api) editDoc(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
+func (a *Api) changeDocAccess(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
-	var m data.Doc
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+
+	var m model.DocAccessRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
-	m = data.Doc{
-		Id:           data.Id(id),
-		AuthorId:     m.AuthorId,
-		Access:       m.Access,
-		Text:         m.Text,
-		Lang:         m.Lang,
-		LinterStatus: "No inspection",
-	}
-	doc, err := a.useCases.EditDoc(data.Id(myId.(string)), m)
-	respJson, err := json.Marshal(doc)
+	m.DocId = data.Id(mux.Vars(r)["doc_id"])
+	doc, err := a.useCases.ChangeDocAccess(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
+	a.writeJson(w, doc)
+}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) getDocAccess(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["doc_id"])
+	acl, err := a.useCases.GetDocAccess(r.Context(), data.Id(myId.(string)), id)
+	if err != nil {
+		a.writeError(w, err)
 		return
 	}
-	println("Edit doc request with id", id, "by user", myId)
+	a.writeJson(w, acl)
 }
 
-func (a *Api) launchLinter(w http.ResponseWriter, r *http.Request) {
-	doc_id := mux.Vars(r)["id"]
+func (a *Api) replaceDocAccess(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
-	err := a.useCases.LaunchLinter(data.Id(myId.(string)), data.Id(doc_id))
+	var m data.DocAcl
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.DocId = data.Id(mux.Vars(r)["doc_id"])
+	acl, err := a.useCases.ReplaceDocAccess(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		if err != model.ErrNoAccess {
-			w.WriteHeader(http.StatusForbidden)
-		} else {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		a.writeError(w, err)
+		return
 	}
+	a.writeJson(w, acl)
 }
 
-func (a *Api) changeDocAccess(w http.ResponseWriter, r *http.Request) {
+func (a *Api) explainDocAccess(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
+	id := data.Id(mux.Vars(r)["doc_id"])
+	targetId := data.Id(r.URL.Query().Get("user"))
+	if targetId == "" {
+		targetId = data.Id(myId.(string))
+	}
+	explanation, err := a.useCases.ExplainDocAccess(r.Context(), data.Id(myId.(string)), id, targetId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, explanation)
+}
 
-	var m model.DocAccessRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+func (a *Api) revokeMemberAccess(w http.ResponseWriter, r *http.Request) {
+	a.revokeDocAccess(w, r, model.MemberAccess, data.Id(mux.Vars(r)["user_id"]))
+}
+
+func (a *Api) revokeGroupAccess(w http.ResponseWriter, r *http.Request) {
+	a.revokeDocAccess(w, r, model.GroupAccess, data.Id(mux.Vars(r)["group_id"]))
+}
+
+func (a *Api) revokeDocAccess(w http.ResponseWriter, r *http.Request, itemType int, itemId data.Id) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
-	doc, err := a.useCases.ChangeDocAccess(data.Id(myId.(string)), m)
+	m := model.DocAccessRequest{
+		DocId:  data.Id(mux.Vars(r)["doc_id"]),
+		Type:   itemType,
+		ItemId: itemId,
+	}
+	err := a.useCases.RevokeDocAccess(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	respJson, err := json.Marshal(doc)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+}
+
+func (a *Api) getAllDocs(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	query := r.URL.Query()
+	filter := model.DocFilter{
+		Tag:      query.Get("tag"),
+		Lang:     query.Get("lang"),
+		Title:    query.Get("title"),
+		EditedBy: data.Id(query.Get("edited_by")),
+		Sort:     query.Get("sort"),
+		Desc:     query.Get("order") == "desc",
+	}
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+	} {
+		if s := query.Get(bound.name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				a.writeError(w, model.ValidationError(model.FieldError{Field: bound.name, Message: "must be an RFC 3339 time"}))
+				return
+			}
+			*bound.value = t
+		}
+	}
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	docs, next, err := a.useCases.GetAllDocs(r.Context(), data.Id(myId.(string)), filter, page)
+	if err != nil {
+		a.writeError(w, err)
 		return
 	}
-	println("Change docs access request by user", myId)
+	a.writePage(w, docs, next)
 }
 
-func (a *Api) getAllDocs(w http.ResponseWriter, r *http.Request) {
+func (a *Api) search(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
-
-	docs, err := a.useCases.GetAllDocs(data.Id(myId.(string)))
+	query := r.URL.Query()
+	m := model.SearchRequest{
+		Query: query.Get("q"),
+		Mode:  query.Get("mode"),
+		Lang:  query.Get("lang"),
+		Owner: data.Id(query.Get("owner")),
+		Tag:   query.Get("tag"),
+	}
+	page, err := pageRequest(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	respJson, err := json.Marshal(docs)
+	results, next, err := a.useCases.Search(r.Context(), data.Id(myId.(string)), m, page)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
+	a.writePage(w, results, next)
+}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) findSymbols(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
-	println("Get all docs request by user", myId)
+	query := r.URL.Query()
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	symbols, next, err := a.useCases.FindSymbols(r.Context(), data.Id(myId.(string)), query.Get("q"), query.Get("kind"), page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, symbols, next)
 }
 
-func (a *Api) getUser(w http.ResponseWriter, r *http.Request) {
+func (a *Api) getDocOutline(w http.ResponseWriter, r *http.Request) {
 	myId := r.Context().Value("myUserId")
 	if myId == nil {
 		return
 	}
-	user, err := a.useCases.GetUserById(data.Id(myId.(string)))
+	docId := data.Id(mux.Vars(r)["doc_id"])
+	page, err := pageRequest(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	respJson, err := json.Marshal(user)
+	symbols, next, err := a.useCases.GetDocOutline(r.Context(), data.Id(myId.(string)), docId, page)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
+	a.writePage(w, symbols, next)
+}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) getUser(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
-	println("Get user request by user", myId)
+	user, err := a.useCases.GetUserById(r.Context(), data.Id(myId.(string)))
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, user)
 }
 
 func (a *Api) editUser(w http.ResponseWriter, r *http.Request) {
@@ -345,30 +566,20 @@ func (a *Api) editUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var m data.User
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
 	m = data.User{
 		Id:    data.Id(myId.(string)),
 		Login: m.Login,
 	}
-	user, err := a.useCases.EditUser(data.Id(myId.(string)), m)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	respJson, err := json.Marshal(user)
+	user, err := a.useCases.EditUser(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Edit user", myId)
+	a.writeJson(w, user)
 }
 
 func (a *Api) createGroup(w http.ResponseWriter, r *http.Request) {
@@ -377,26 +588,16 @@ func (a *Api) createGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var m data.Group
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
-	group, err := a.useCases.CreateGroup(data.Id(myId.(string)), m)
+	group, err := a.useCases.CreateGroup(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	respJson, err := json.Marshal(group)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	println("create group request by user", myId)
+	a.writeJson(w, group)
 }
 
 func (a *Api) editGroup(w http.ResponseWriter, r *http.Request) {
@@ -404,34 +605,27 @@ func (a *Api) editGroup(w http.ResponseWriter, r *http.Request) {
 	if myId == nil {
 		return
 	}
-	id := mux.Vars(r)["id"]
+	id := data.Id(mux.Vars(r)["group_id"])
 
 	var m data.Group
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
+	if id == "" {
+		id = m.Id
+	}
 	m = data.Group{
-		Id:      data.Id(id),
+		Id:      id,
 		Name:    m.Name,
 		Creator: m.Creator,
 	}
-	group, err := a.useCases.EditGroup(data.Id(myId.(string)), m)
+	group, err := a.useCases.EditGroup(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	respJson, err := json.Marshal(group)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Edit group request with group id ", id, "by user", myId)
+	a.writeJson(w, group)
 }
 
 func (a *Api) deleteGroup(w http.ResponseWriter, r *http.Request) {
@@ -439,13 +633,20 @@ func (a *Api) deleteGroup(w http.ResponseWriter, r *http.Request) {
 	if myId == nil {
 		return
 	}
-	id := mux.Vars(r)["id"]
-	err := a.useCases.DeleteGroup(data.Id(myId.(string)), data.Id(id))
+	id := data.Id(mux.Vars(r)["group_id"])
+	if id == "" {
+		var m data.Group
+		if err := decodeJson(r, &m); err != nil {
+			a.writeError(w, err)
+			return
+		}
+		id = m.Id
+	}
+	err := a.useCases.DeleteGroup(r.Context(), data.Id(myId.(string)), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Delete group request with group id ", id, "by user", myId)
 }
 
 func (a *Api) removeMember(w http.ResponseWriter, r *http.Request) {
@@ -454,16 +655,16 @@ func (a *Api) removeMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var m model.MemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
-	err := a.useCases.RemoveMember(data.Id(myId.(string)), m.GroupId, m.MemberId)
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.RemoveMember(r.Context(), data.Id(myId.(string)), m.GroupId, m.MemberId)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Add member to group request with group id ", m.GroupId, "by user", myId)
 }
 
 func (a *Api) addMember(w http.ResponseWriter, r *http.Request) {
@@ -472,16 +673,16 @@ func (a *Api) addMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var m model.MemberRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
 		return
 	}
-	err := a.useCases.AddMember(data.Id(myId.(string)), m.GroupId, m.MemberId)
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.AddMember(r.Context(), data.Id(myId.(string)), m.GroupId, m.MemberId)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
-	println("Add member to group request with group id ", m.GroupId, "by user", myId)
 }
 
 func (a *Api) getMembers(w http.ResponseWriter, r *http.Request) {
@@ -489,25 +690,107 @@ func (a *Api) getMembers(w http.ResponseWriter, r *http.Request) {
 	if myId == nil {
 		return
 	}
-	var m model.GroupMembersChunkRequest
-	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	id := data.Id(mux.Vars(r)["group_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
 		return
 	}
-	members, err := a.useCases.GetMembers(data.Id(myId.(string)), m)
+	members, next, err := a.useCases.GetMembers(r.Context(), data.Id(myId.(string)), id, page)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, members, next)
+}
+
+func (a *Api) setMemberRole(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
 		return
 	}
-	respJson, err := json.Marshal(members)
+	var m model.RoleRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.SetMemberRole(r.Context(), data.Id(myId.(string)), m)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		a.writeError(w, err)
 		return
 	}
+}
 
-	if _, err := w.Write(respJson); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (a *Api) transferGroupOwnership(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.OwnerRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.TransferGroupOwnership(r.Context(), data.Id(myId.(string)), m.GroupId, m.OwnerId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) addSubgroup(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.SubgroupRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.AddSubgroup(r.Context(), data.Id(myId.(string)), m.GroupId, m.SubgroupId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) removeSubgroup(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.SubgroupRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.GroupId = data.Id(mux.Vars(r)["group_id"])
+	err := a.useCases.RemoveSubgroup(r.Context(), data.Id(myId.(string)), m.GroupId, m.SubgroupId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) getSubgroups(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["group_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	groups, next, err := a.useCases.GetSubgroups(r.Context(), data.Id(myId.(string)), id, page)
+	if err != nil {
+		a.writeError(w, err)
 		return
 	}
-	println("Get group members request with group id ", m.Id, "by user", myId)
+	a.writePage(w, groups, next)
 }
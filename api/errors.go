@@ -0,0 +1,112 @@
+package api
+
+import (
+	"doccer/data"
+	"doccer/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+type errorBody struct {
+	Code    string             `json:"code"`
+	Message string             `json:"message"`
+	Details []model.FieldError `json:"details,omitempty"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+var errorStatuses = map[string]int{
+	model.ErrInvalidRequest.Code:  http.StatusBadRequest,
+	model.ErrWrongPassword.Code:   http.StatusUnauthorized,
+	model.ErrUnauthorized.Code:    http.StatusUnauthorized,
+	model.ErrNoAccess.Code:        http.StatusForbidden,
+	model.ErrNotFound.Code:        http.StatusNotFound,
+	model.ErrAlreadyExists.Code:   http.StatusConflict,
+	model.ErrGroupCycle.Code:      http.StatusConflict,
+	model.ErrFolderCycle.Code:     http.StatusConflict,
+	model.ErrVersionConflict.Code: http.StatusConflict,
+	model.ErrValidation.Code:      http.StatusUnprocessableEntity,
+	model.ErrNotImplemented.Code:  http.StatusNotImplemented,
+	model.ErrUnavailable.Code:     http.StatusServiceUnavailable,
+	model.ErrRateLimited.Code:     http.StatusTooManyRequests,
+	model.ErrQuotaExceeded.Code:   http.StatusForbidden,
+	model.ErrTooLarge.Code:        http.StatusRequestEntityTooLarge,
+}
+
+// writeError answers with the status of a domain error and its code,
+// message and details. Any other error is reported as an internal one
+// without leaking its text to the client.
+func (a *Api) writeError(w http.ResponseWriter, err error) {
+	var domainErr *model.Error
+	if !errors.As(err, &domainErr) {
+		recordError(w, err)
+		domainErr = &model.Error{Code: "internal", Message: "internal error"}
+	}
+	status, ok := errorStatuses[domainErr.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: errorBody{
+		Code:    domainErr.Code,
+		Message: domainErr.Message,
+		Details: domainErr.Details,
+	}})
+}
+
+// decodeJson reads the request body into v. Bodies over the size limit are
+// too large and bodies that are not JSON are invalid requests, while bodies
+// that are not UTF-8, unknown access levels and roles and values of the
+// wrong type fail validation.
+func decodeJson(r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return &model.Error{
+			Code:    model.ErrTooLarge.Code,
+			Message: "request body too large",
+			Details: []model.FieldError{{Message: fmt.Sprintf("must be at most %d bytes", maxErr.Limit)}},
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// json would quietly replace invalid bytes in strings
+	if !utf8.Valid(body) {
+		return model.ValidationError(model.FieldError{Message: "body must be valid UTF-8"})
+	}
+	err = json.Unmarshal(body, v)
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, data.ErrUnknownAccessLevel), errors.Is(err, data.ErrUnknownRole):
+		// json does not tell which field an Unmarshaler failed on
+		return model.ValidationError(model.FieldError{Message: err.Error()})
+	case errors.As(err, &typeErr):
+		return model.ValidationError(model.FieldError{Field: typeErr.Field, Message: "must be " + typeErr.Type.String()})
+	}
+	return &model.Error{
+		Code:    model.ErrInvalidRequest.Code,
+		Message: "malformed JSON body: " + err.Error(),
+	}
+}
+
+// limitBody bounds the request bodies the handlers read.
+func (a *Api) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if max := a.validator.MaxBodyBytes(); max > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"doccer/data"
+	"doccer/model"
+	mux "github.com/gorilla/mux"
+	"net/http"
+)
+
+func (a *Api) createFolder(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m data.Folder
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	folder, err := a.useCases.CreateFolder(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, folder)
+}
+
+func (a *Api) getUserFolders(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	folders, next, err := a.useCases.GetUserFolders(r.Context(), data.Id(myId.(string)), page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, folders, next)
+}
+
+func (a *Api) getFolder(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	contents, err := a.useCases.GetFolder(r.Context(), data.Id(myId.(string)), id, page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, contents)
+}
+
+func (a *Api) getSubfolders(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	folders, next, err := a.useCases.GetSubfolders(r.Context(), data.Id(myId.(string)), id, page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, folders, next)
+}
+
+func (a *Api) renameFolder(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m data.Folder
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	folder, err := a.useCases.RenameFolder(r.Context(), data.Id(myId.(string)), id, m.Name)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, folder)
+}
+
+func (a *Api) moveFolder(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.MoveRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	folder, err := a.useCases.MoveFolder(r.Context(), data.Id(myId.(string)), id, m.FolderId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, folder)
+}
+
+func (a *Api) deleteFolder(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	err := a.useCases.DeleteFolder(r.Context(), data.Id(myId.(string)), id)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) moveDoc(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.MoveRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	id := data.Id(mux.Vars(r)["doc_id"])
+	doc, err := a.useCases.MoveDoc(r.Context(), data.Id(myId.(string)), id, m.FolderId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, doc)
+}
+
+func (a *Api) changeFolderAccess(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.DocAccessRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.DocId = data.Id(mux.Vars(r)["folder_id"])
+	acl, err := a.useCases.ChangeFolderAccess(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, acl)
+}
+
+func (a *Api) getFolderAccess(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["folder_id"])
+	acl, err := a.useCases.GetFolderAccess(r.Context(), data.Id(myId.(string)), id)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, acl)
+}
+
+func (a *Api) revokeFolderMemberAccess(w http.ResponseWriter, r *http.Request) {
+	a.revokeFolderAccess(w, r, model.MemberAccess, data.Id(mux.Vars(r)["user_id"]))
+}
+
+func (a *Api) revokeFolderGroupAccess(w http.ResponseWriter, r *http.Request) {
+	a.revokeFolderAccess(w, r, model.GroupAccess, data.Id(mux.Vars(r)["group_id"]))
+}
+
+func (a *Api) revokeFolderAccess(w http.ResponseWriter, r *http.Request, itemType int, itemId data.Id) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	m := model.DocAccessRequest{
+		DocId:  data.Id(mux.Vars(r)["folder_id"]),
+		Type:   itemType,
+		ItemId: itemId,
+	}
+	err := a.useCases.RevokeFolderAccess(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"net/http"
+	runtimePprof "runtime/pprof"
+	"time"
+)
+
+// probeRoutes are polled by orchestrators; their successes are only logged
+// at debug level.
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+func (a *Api) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (a *Api) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	readiness := a.useCases.CheckReadiness(ctx)
+	if !readiness.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	a.writeJson(w, readiness)
+}
+
+// admin lets only the configured admins through.
+func (a *Api) admin(f http.HandlerFunc) http.HandlerFunc {
+	return a.auth(func(w http.ResponseWriter, r *http.Request) {
+		myId := r.Context().Value("myUserId")
+		isAdmin, err := a.useCases.IsAdmin(r.Context(), data.Id(myId.(string)))
+		if err != nil {
+			a.writeError(w, err)
+			return
+		}
+		if !isAdmin {
+			a.writeError(w, model.ErrNoAccess)
+			return
+		}
+		f(w, r)
+	}, true)
+}
+
+// debugWriteTimeout bounds the responses of the pprof handlers, which
+// profile for 30s by default, instead of http.write_timeout.
+const debugWriteTimeout = 10 * time.Minute
+
+// longWrite lets f write for up to debugWriteTimeout. pprof refuses
+// durations past the WriteTimeout of the server in the request context, so
+// the server is taken out of it.
+func longWrite(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(debugWriteTimeout)); err != nil {
+			f(w, r)
+			return
+		}
+		f(w, r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, nil)))
+	}
+}
+
+func (a *Api) getGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_ = runtimePprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func (a *Api) getLintJobs(w http.ResponseWriter, r *http.Request) {
+	a.writeJson(w, a.useCases.GetLintJobs(r.Context()))
+}
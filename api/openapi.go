@@ -0,0 +1,69 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	mux "github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// spec is the OpenAPI 3 description of the routes registered in Router.
+//go:embed openapi.json
+var spec []byte
+
+func (a *Api) getSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(spec)
+}
+
+// SpecMismatches compares the routes of the router with the paths of the
+// OpenAPI spec and describes every operation only one of them has.
+func (a *Api) SpecMismatches() ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	documented := map[string]bool{}
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routed := map[string]bool{}
+	err := a.router().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routed[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for op := range routed {
+		if !documented[op] {
+			res = append(res, op+" is not in the spec")
+		}
+	}
+	for op := range documented {
+		if !routed[op] {
+			res = append(res, op+" is not routed")
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"doccer/config"
+	"log/slog"
+	"testing"
+)
+
+func TestSpecMatchesRouter(t *testing.T) {
+	a := NewApi(nil, slog.Default(), config.RateLimitConfig{}, nil)
+	mismatches, err := a.SpecMismatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mismatch := range mismatches {
+		t.Error(mismatch)
+	}
+}
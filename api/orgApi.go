@@ -0,0 +1,182 @@
+package api
+
+import (
+	"doccer/data"
+	"doccer/model"
+	mux "github.com/gorilla/mux"
+	"net/http"
+)
+
+func (a *Api) createOrg(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m data.Org
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	org, err := a.useCases.CreateOrg(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, org)
+}
+
+func (a *Api) getUserOrgs(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	orgs, next, err := a.useCases.GetUserOrgs(r.Context(), data.Id(myId.(string)), page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, orgs, next)
+}
+
+func (a *Api) getOrg(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["org_id"])
+	org, err := a.useCases.GetOrg(r.Context(), data.Id(myId.(string)), id)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, org)
+}
+
+func (a *Api) editOrg(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m data.Org
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.Id = data.Id(mux.Vars(r)["org_id"])
+	org, err := a.useCases.EditOrg(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writeJson(w, org)
+}
+
+func (a *Api) deleteOrg(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["org_id"])
+	err := a.useCases.DeleteOrg(r.Context(), data.Id(myId.(string)), id)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) getOrgDocs(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["org_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	docs, next, err := a.useCases.GetOrgDocs(r.Context(), data.Id(myId.(string)), id, page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, docs, next)
+}
+
+func (a *Api) getOrgMembers(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	id := data.Id(mux.Vars(r)["org_id"])
+	page, err := pageRequest(r)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	members, next, err := a.useCases.GetOrgMembers(r.Context(), data.Id(myId.(string)), id, page)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+	a.writePage(w, members, next)
+}
+
+func (a *Api) addOrgMember(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.OrgMemberRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.OrgId = data.Id(mux.Vars(r)["org_id"])
+	err := a.useCases.AddOrgMember(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) removeOrgMember(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.OrgMemberRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.OrgId = data.Id(mux.Vars(r)["org_id"])
+	err := a.useCases.RemoveOrgMember(r.Context(), data.Id(myId.(string)), m.OrgId, m.MemberId)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
+
+func (a *Api) setOrgMemberRole(w http.ResponseWriter, r *http.Request) {
+	myId := r.Context().Value("myUserId")
+	if myId == nil {
+		return
+	}
+	var m model.OrgMemberRequest
+	if err := decodeJson(r, &m); err != nil {
+		a.writeError(w, err)
+		return
+	}
+	m.OrgId = data.Id(mux.Vars(r)["org_id"])
+	err := a.useCases.SetOrgMemberRole(r.Context(), data.Id(myId.(string)), m)
+	if err != nil {
+		a.writeError(w, err)
+		return
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"doccer/model"
+	"doccer/ratelimit"
+	mux "github.com/gorilla/mux"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// lintRoutes queue a linter run, so they also take from the lint budget.
+var lintRoutes = map[string]bool{
+	"POST /docs":                true,
+	"PUT /docs/{doc_id}":        true,
+	"GET /docs/{doc_id}/linter": true,
+}
+
+// rateLimit answers 429 with Retry-After to clients out of tokens. Writes
+// and lint requests have their own budgets, kept per user, or per client
+// IP for anonymous requests.
+func (a *Api) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var limiters []*ratelimit.Limiter
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			limiters = append(limiters, a.writeLimiter)
+		}
+		if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil && lintRoutes[r.Method+" "+template] {
+			limiters = append(limiters, a.lintLimiter)
+		}
+
+		if wait, ok := ratelimit.Allow(a.clientKey(r), limiters...); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			a.writeError(w, model.ErrRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Api) clientKey(r *http.Request) string {
+	if myId, ok := r.Context().Value("myUserId").(string); ok {
+		return "user:" + myId
+	}
+	// the proxy appends the address it saw, anything before it may be forged
+	if a.trustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return "ip:" + ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
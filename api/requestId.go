@@ -0,0 +1,25 @@
+package api
+
+import (
+	"crypto/rand"
+	"doccer/model"
+	"encoding/hex"
+	"net/http"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// withRequestId puts the request id of the client, or a new one, into the
+// request context and the response headers.
+func withRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIdHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(model.WithRequestId(r.Context(), id)))
+	})
+}
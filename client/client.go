@@ -2,143 +2,347 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"doccer/data"
+	"doccer/model"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io/ioutil"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
+	"time"
 )
 
-
-// Test client
+// Client calls the doccer API. Requests and responses use the same types
+// as the server, so both sides agree on the JSON field names.
 type Client struct {
-	url string
-	client http.Client
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	login    string
+	password string
+
+	retries     int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	middlewares []Middleware
 }
 
-func NewClient(url string) Client {
-	return Client {
-		url: url,
-		client: http.Client {},
+type Option func(c *Client)
+
+// WithHTTPClient sends requests through a copy of client instead of
+// a default one. Middlewares wrap its transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		copied := *client
+		c.client = &copied
 	}
 }
 
-// Returns user id
-func (c* Client) Register(login string, password string) (string, error) {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"login":"%s", "password":"%s"}`, login, password)))
-	reqUrl := c.url + "/register"
-	resp, err := c.client.Post(reqUrl, "application/json", reqJson)
-	if err != nil {
-		return "", err
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
 	}
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-	return c.parseField(bodyBytes, "id")
 }
 
-// Returns jwt
-func (c* Client) Login(login string, password string) (string, error) {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"login":"%s", "password":"%s"}`, login, password)))
-	resp, err := c.client.Post(c.url + "/login", "application/json", reqJson)
-	if err != nil {
-		return "", err
+// WithCredentials lets the client log in again when the server rejects its
+// token, e.g. once the token expires.
+func WithCredentials(login string, password string) Option {
+	return func(c *Client) {
+		c.login = login
+		c.password = password
 	}
-	if resp.StatusCode == http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return c.parseField(bodyBytes, "Token")
-	} else {
-		return "", errors.New("Error: " + strconv.Itoa(resp.StatusCode))
+}
 
+// WithRetry sets how many times idempotent calls are retried after
+// network errors and 429, 502, 503 and 504 answers, and the delay before
+// the first retry; the delay doubles with every attempt. Version-checked
+// edits are only retried after 429.
+func WithRetry(retries int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.baseDelay = baseDelay
 	}
 }
 
-// Returns docId
-func (c* Client) CreateDoc(text string, lang string, defaultAccess string, token string) (string, error) {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"id":"-1", "authorId":"-1", "text":%s, "access":"%s", "lang":"%s", "lstatus":"%s"}`,
-			c.escapeJson(text), defaultAccess, lang, "")))
+// Middleware wraps the transport of the client, e.g. to log or sign
+// requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc turns a function into an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
 
-	req, _ := http.NewRequest("POST", c.url + "/docs", reqJson)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
 
-	resp, _ := c.makeAuthRequest(*req, token)
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-	return c.parseField(bodyBytes, "id")
+// WithMiddleware wraps the transport with middlewares; the first one sees
+// requests first.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
 }
 
+func NewClient(url string, opts ...Option) *Client {
+	c := &Client{
+		url:       url,
+		client:    &http.Client{},
+		retries:   3,
+		baseDelay: 100 * time.Millisecond,
+		maxDelay:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-// Returns groupId
-func (c* Client) CreateGroup(name string, token string) (string, error) {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"id":"-1", "creatorId":"-1", "name":"%s"}`, name)))
+	transport := c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		transport = c.middlewares[i](transport)
+	}
+	c.client.Transport = transport
+	return c
+}
 
-	req, _ := http.NewRequest("POST", c.url + "/users/groups", reqJson)
+// Token returns the token sent with every request.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
 
-	resp, _ := c.makeAuthRequest(*req, token)
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-	return c.parseField(bodyBytes, "id")
+// SetToken sets the token sent with every request; Login sets it too.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
 }
 
-func (c* Client) AddMember(groupId string, memberId string, token string) error {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"groupId":"%s", "memberId":"%s"}`, groupId, memberId)))
+func (c *Client) Register(ctx context.Context, login string, password string) (*data.User, error) {
+	var user data.User
+	err := c.do(ctx, http.MethodPost, "/register", nil, model.LoginRequest{Login: login, Password: password}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
 
-	req, _ := http.NewRequest("PUT", c.url + fmt.Sprintf("/users/groups/%s/members", groupId), reqJson)
+// Login logs in, uses the new token for the next requests and remembers
+// the credentials to log in again when the token expires.
+func (c *Client) Login(ctx context.Context, login string, password string) (*model.LoginResponse, error) {
+	res, err := c.loginOnce(ctx, login, password)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.login = login
+	c.password = password
+	c.mu.Unlock()
+	return res, nil
+}
 
-	_, _ = c.makeAuthRequest(*req, token)
-	return nil
+func (c *Client) loginOnce(ctx context.Context, login string, password string) (*model.LoginResponse, error) {
+	var res model.LoginResponse
+	err := c.send(ctx, http.MethodPost, false, "/login", nil, model.LoginRequest{Login: login, Password: password}, &res, false)
+	if err != nil {
+		return nil, err
+	}
+	c.SetToken(string(res.Token))
+	return &res, nil
 }
 
-func (c* Client) ChangeMemberAccess(docId string, memberId string, newAccess string, token string) error {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"id":"%s", "type":0, "itemId":"%s", "access":"%s"}`, docId, memberId, newAccess)))
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/logout", nil, nil, nil)
+}
 
-	req, _ := http.NewRequest("POST", c.url + fmt.Sprintf("/docs/%s/access", docId), reqJson)
+// GetUser returns the logged in user.
+func (c *Client) GetUser(ctx context.Context) (*data.User, error) {
+	var res data.User
+	if err := c.do(ctx, http.MethodGet, "/users", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
 
-	_, _ = c.makeAuthRequest(*req, token)
-	return nil
+func (c *Client) EditUser(ctx context.Context, user data.User) (*data.User, error) {
+	var res data.User
+	if err := c.do(ctx, http.MethodPut, "/users", nil, user, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
 }
 
-func (c* Client) ChangeGroupAccess(docId string, groupId string, newAccess string, token string) error {
-	reqJson := bytes.NewBuffer([]byte(
-		fmt.Sprintf(`{"id":"%s", "type":1, "itemId":"%s", "access":"%s"}`, docId, groupId, newAccess)))
+// Spec returns the OpenAPI description of the server.
+func (c *Client) Spec(ctx context.Context) (json.RawMessage, error) {
+	var res json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
-	req, _ := http.NewRequest("POST", c.url + fmt.Sprintf("/docs/%s/access", docId), reqJson)
+// do sends in as the JSON body and decodes the response into out; either
+// may be nil. A rejected token is renewed once if the client knows the
+// credentials.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, in interface{}, out interface{}) error {
+	return c.call(ctx, method, idempotent(method), path, query, in, out)
+}
 
-	_, _ = c.makeAuthRequest(*req, token)
-	return nil
+// call is do for requests whose retry safety the method alone doesn't
+// tell, such as version-checked edits: repeating one that succeeded but
+// whose answer was lost would fail with a conflict.
+func (c *Client) call(ctx context.Context, method string, retryable bool, path string, query url.Values,
+	in interface{}, out interface{}) error {
+	err := c.send(ctx, method, retryable, path, query, in, out, true)
+	if !errors.Is(err, model.ErrUnauthorized) {
+		return err
+	}
+	c.mu.Lock()
+	login, password := c.login, c.password
+	c.mu.Unlock()
+	if login == "" {
+		return err
+	}
+	if _, loginErr := c.loginOnce(ctx, login, password); loginErr != nil {
+		return err
+	}
+	return c.send(ctx, method, retryable, path, query, in, out, true)
 }
 
-func (c* Client) GetDoc(docId string, token string) (*data.Doc, error) {
+func (c *Client) send(ctx context.Context, method string, retryable bool, path string, query url.Values,
+	in interface{}, out interface{}, withToken bool) error {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	reqUrl := c.url + path
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
 
-	req, _ := http.NewRequest("GET", c.url + fmt.Sprintf("/docs/%s", docId), nil)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.roundTrip(ctx, method, reqUrl, body, withToken)
+		if attempt < c.retries && ctx.Err() == nil {
+			if delay, retry := c.retryDelay(retryable, attempt, resp, err); retry {
+				if resp != nil {
+					_ = resp.Body.Close()
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	resp, _ := c.makeAuthRequest(*req, token)
+		if resp.StatusCode >= http.StatusBadRequest {
+			return decodeError(resp)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
 
-	var res *data.Doc
-	err := json.NewDecoder(resp.Body).Decode(&res)
+func (c *Client) roundTrip(ctx context.Context, method string, reqUrl string, body []byte, withToken bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, reader)
 	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); withToken && token != "" {
+		req.Header.Set("AuthToken", token)
+	}
+	return c.client.Do(req)
+}
+
+// retryDelay tells whether a failed attempt is worth retrying and how long
+// to wait first, honouring Retry-After. Only retryable calls are retried,
+// except after 429, which the server answers before doing anything.
+func (c *Client) retryDelay(retryable bool, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err == nil {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests:
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			if !retryable {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if err != nil && !retryable {
+		return 0, false
+	}
+	delay := c.baseDelay << uint(attempt)
+	if delay > c.maxDelay || delay <= 0 {
+		delay = c.maxDelay
+	}
+	// full jitter keeps clients that failed together from retrying together
+	return time.Duration(rand.Int63n(int64(delay) + 1)), true
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
 }
 
-func (c* Client) makeAuthRequest(request http.Request, token string) (*http.Response, error)  {
-	request.Header.Add("AuthToken", token)
-	resp, err := c.client.Do(&request)
-	return resp, err
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
-func (c* Client) parseField(responseBody []byte, fieldName string) (string, error) {
-	bodyStr := string(responseBody)
-	var result map[string]interface{}
-	_ = json.Unmarshal([]byte(bodyStr), &result)
-	return result[fieldName].(string), nil
+func escape(id data.Id) string {
+	return url.PathEscape(string(id))
 }
 
-func (c* Client) escapeJson(jsonString string) string {
-	res, _ := json.Marshal(jsonString)
-	return string(res)
-}
\ No newline at end of file
+// pageQuery encodes the page parameters of a listing.
+func pageQuery(query url.Values, page model.PageRequest) url.Values {
+	if query == nil {
+		query = url.Values{}
+	}
+	if page.Cursor != "" {
+		query.Set("cursor", page.Cursor)
+	}
+	if page.Limit > 0 {
+		query.Set("limit", strconv.Itoa(page.Limit))
+	}
+	if page.Summary {
+		query.Set("view", "summary")
+	}
+	return query
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeError(w http.ResponseWriter, status int, err *model.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{
+		"code":    err.Code,
+		"message": err.Message,
+		"details": err.Details,
+	}})
+}
+
+func writeDoc(w http.ResponseWriter, id data.Id) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data.Doc{Id: id})
+}
+
+// fastRetries keeps the backoff of the tests short.
+var fastRetries = WithRetry(3, time.Millisecond)
+
+func TestErrorDecoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/1":
+			writeError(w, http.StatusUnprocessableEntity, &model.Error{
+				Code:    model.ErrValidation.Code,
+				Message: model.ErrValidation.Message,
+				Details: []model.FieldError{{Field: "lang", Message: "must be empty or one of Text, go"}},
+			})
+		default:
+			http.Error(w, "teapot", http.StatusTeapot)
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, fastRetries)
+
+	_, err := c.GetDoc(context.Background(), "1")
+	if !errors.Is(err, model.ErrValidation) {
+		t.Fatalf("err = %v, want validation_failed", err)
+	}
+	var clientErr *Error
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("err is %T, want *Error", err)
+	}
+	if clientErr.Status != http.StatusUnprocessableEntity || len(clientErr.Details) != 1 || clientErr.Details[0].Field != "lang" {
+		t.Errorf("err = %+v", clientErr)
+	}
+	if errors.Is(err, model.ErrNotFound) {
+		t.Error("err matches not_found")
+	}
+
+	// bodies that are not error JSON keep only the status
+	_, err = c.GetDoc(context.Background(), "2")
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("err is %T, want *Error", err)
+	}
+	if clientErr.Status != http.StatusTeapot || clientErr.Code != "" || clientErr.Message != http.StatusText(http.StatusTeapot) {
+		t.Errorf("err = %+v", clientErr)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		status    int
+		failures  int32
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"GET after 503", http.MethodGet, http.StatusServiceUnavailable, 2, 3, false},
+		{"GET gives up", http.MethodGet, http.StatusBadGateway, 10, 4, true},
+		{"POST after 429", http.MethodPost, http.StatusTooManyRequests, 1, 2, false},
+		{"POST not after 503", http.MethodPost, http.StatusServiceUnavailable, 1, 1, true},
+		{"GET not after 500", http.MethodGet, http.StatusInternalServerError, 1, 1, true},
+		{"PUT after 503", http.MethodPut, http.StatusServiceUnavailable, 1, 2, false},
+		{"checked PUT not after 503", "checked", http.StatusServiceUnavailable, 1, 1, true},
+		{"checked PUT after 429", "checked", http.StatusTooManyRequests, 1, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tt.failures {
+					w.Header().Set("Retry-After", "0")
+					writeError(w, tt.status, &model.Error{Code: "failed", Message: "failed"})
+					return
+				}
+				writeDoc(w, "1")
+			}))
+			defer srv.Close()
+			c := NewClient(srv.URL, fastRetries)
+
+			var err error
+			switch tt.method {
+			case http.MethodGet:
+				_, err = c.GetDoc(context.Background(), "1")
+			case http.MethodPut:
+				_, err = c.EditDoc(context.Background(), model.DocEdit{Id: "1"})
+			case "checked":
+				_, err = c.EditDoc(context.Background(), model.DocEdit{Id: "1", Version: 3})
+			default:
+				_, err = c.CreateDoc(context.Background(), data.Doc{Text: "x"})
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryAfterNetworkError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// drop the connection without an answer
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		writeDoc(w, "1")
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL, fastRetries).GetDoc(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	c := NewClient("http://doccer", WithRetry(5, 100*time.Millisecond))
+	c.maxDelay = time.Second
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			delay, retry := c.retryDelay(true, attempt, unavailable, nil)
+			if !retry || delay < 0 || delay > max {
+				t.Fatalf("attempt %d: delay = %v, %t, want up to %v", attempt, delay, retry, max)
+			}
+		}
+	}
+
+	limited := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	if delay, retry := c.retryDelay(false, 0, limited, nil); !retry || delay != 7*time.Second {
+		t.Errorf("Retry-After: delay = %v, %t, want 7s", delay, retry)
+	}
+	if _, retry := c.retryDelay(false, 0, nil, errors.New("connection reset")); retry {
+		t.Error("POST retried after a network error")
+	}
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusServiceUnavailable, model.ErrUnavailable)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.URL, WithRetry(5, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.GetDoc(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the backoff outlived the context")
+	}
+}
+
+// authServer accepts the token "fresh", which /login hands out.
+func authServer(logins *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			atomic.AddInt32(logins, 1)
+			var req model.LoginRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password != "secret" {
+				writeError(w, http.StatusUnauthorized, model.ErrWrongPassword)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(model.LoginResponse{Token: "fresh", User: data.User{Id: "1", Login: req.Login}})
+		default:
+			if r.Header.Get("AuthToken") != "fresh" {
+				writeError(w, http.StatusUnauthorized, model.ErrUnauthorized)
+				return
+			}
+			writeDoc(w, "1")
+		}
+	}))
+}
+
+func TestTokenRefresh(t *testing.T) {
+	var logins int32
+	srv := authServer(&logins)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithToken("expired"), WithCredentials("jacob", "secret"), fastRetries)
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetDoc(context.Background(), "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("logins = %d, want 1", logins)
+	}
+	if c.Token() != "fresh" {
+		t.Errorf("token = %q, want fresh", c.Token())
+	}
+}
+
+func TestTokenRefreshFails(t *testing.T) {
+	var logins int32
+	srv := authServer(&logins)
+	defer srv.Close()
+
+	// without credentials the rejection is returned as is
+	c := NewClient(srv.URL, WithToken("expired"), fastRetries)
+	if _, err := c.GetDoc(context.Background(), "1"); !errors.Is(err, model.ErrUnauthorized) {
+		t.Errorf("err = %v, want unauthorized", err)
+	}
+	// a failed login reports the original rejection, once
+	c = NewClient(srv.URL, WithToken("expired"), WithCredentials("jacob", "wrong"), fastRetries)
+	if _, err := c.GetDoc(context.Background(), "1"); !errors.Is(err, model.ErrUnauthorized) {
+		t.Errorf("err = %v, want unauthorized", err)
+	}
+	if logins != 1 {
+		t.Errorf("logins = %d, want 1", logins)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Trace")
+		writeDoc(w, "1")
+	}))
+	defer srv.Close()
+
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				req.Header.Set("X-Trace", strings.TrimPrefix(req.Header.Get("X-Trace")+","+name, ","))
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	c := NewClient(srv.URL, WithHTTPClient(&http.Client{Timeout: 5 * time.Second}), WithMiddleware(tag("outer"), tag("inner")))
+	if _, err := c.GetDoc(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("order = %v, want outer then inner", order)
+	}
+	if seen != "outer,inner" {
+		t.Errorf("X-Trace = %q, want outer,inner", seen)
+	}
+}
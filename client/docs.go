@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func (c *Client) CreateDoc(ctx context.Context, doc data.Doc) (*data.Doc, error) {
+	var res data.Doc
+	if err := c.do(ctx, http.MethodPost, "/docs", nil, doc, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetDoc(ctx context.Context, docId data.Id) (*data.Doc, error) {
+	var res data.Doc
+	if err := c.do(ctx, http.MethodGet, "/docs/"+escape(docId), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// EditDoc changes the fields set in edit; the others keep their values.
+func (c *Client) EditDoc(ctx context.Context, edit model.DocEdit) (*data.Doc, error) {
+	var res data.Doc
+	// a version-checked edit isn't idempotent, its retry would conflict
+	if err := c.call(ctx, http.MethodPut, edit.Version == 0, "/docs/"+escape(edit.Id), nil, edit, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) DeleteDoc(ctx context.Context, docId data.Id) error {
+	return c.do(ctx, http.MethodDelete, "/docs/"+escape(docId), nil, nil, nil)
+}
+
+// ListDocs returns a page of the docs the user can read.
+func (c *Client) ListDocs(ctx context.Context, filter model.DocFilter, page model.PageRequest) (*DocPage, error) {
+	query := url.Values{}
+	setIfNotEmpty(query, "tag", filter.Tag)
+	setIfNotEmpty(query, "lang", filter.Lang)
+	setIfNotEmpty(query, "title", filter.Title)
+	for _, bound := range []struct {
+		name  string
+		value time.Time
+	}{
+		{"created_after", filter.CreatedAfter},
+		{"created_before", filter.CreatedBefore},
+		{"updated_after", filter.UpdatedAfter},
+		{"updated_before", filter.UpdatedBefore},
+	} {
+		if !bound.value.IsZero() {
+			query.Set(bound.name, bound.value.Format(time.RFC3339Nano))
+		}
+	}
+	setIfNotEmpty(query, "edited_by", string(filter.EditedBy))
+	setIfNotEmpty(query, "sort", filter.Sort)
+	if filter.Desc {
+		query.Set("order", "desc")
+	}
+	var res DocPage
+	if err := c.do(ctx, http.MethodGet, "/docs", pageQuery(query, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// LaunchLinter starts the linter on the doc; its status shows up in
+// LinterStatus of the doc once it finishes.
+func (c *Client) LaunchLinter(ctx context.Context, docId data.Id) error {
+	return c.do(ctx, http.MethodGet, "/docs/"+escape(docId)+"/linter", nil, nil, nil)
+}
+
+func (c *Client) GetDocOutline(ctx context.Context, docId data.Id, page model.PageRequest) (*SymbolPage, error) {
+	var res SymbolPage
+	if err := c.do(ctx, http.MethodGet, "/docs/"+escape(docId)+"/outline", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MoveDoc puts the doc into a folder, or out of any folder if folderId is
+// empty.
+func (c *Client) MoveDoc(ctx context.Context, docId data.Id, folderId data.Id) (*data.Doc, error) {
+	var res data.Doc
+	err := c.do(ctx, http.MethodPut, "/docs/"+escape(docId)+"/folder", nil, model.MoveRequest{FolderId: folderId}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) ChangeDocAccess(ctx context.Context, request model.DocAccessRequest) (*data.Doc, error) {
+	var res data.Doc
+	if err := c.do(ctx, http.MethodPost, "/docs/"+escape(request.DocId)+"/access", nil, request, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetDocAccess(ctx context.Context, docId data.Id) (*data.DocAcl, error) {
+	var res data.DocAcl
+	if err := c.do(ctx, http.MethodGet, "/docs/"+escape(docId)+"/access", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) ReplaceDocAccess(ctx context.Context, acl data.DocAcl) (*data.DocAcl, error) {
+	var res data.DocAcl
+	if err := c.do(ctx, http.MethodPut, "/docs/"+escape(acl.DocId)+"/access", nil, acl, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ExplainDocAccess tells where the access of a user to the doc comes from;
+// an empty userId means the logged in user.
+func (c *Client) ExplainDocAccess(ctx context.Context, docId data.Id, userId data.Id) (*data.AccessExplanation, error) {
+	query := url.Values{}
+	setIfNotEmpty(query, "user", string(userId))
+	var res data.AccessExplanation
+	if err := c.do(ctx, http.MethodGet, "/docs/"+escape(docId)+"/access/explain", query, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) RevokeDocMemberAccess(ctx context.Context, docId data.Id, userId data.Id) error {
+	return c.do(ctx, http.MethodDelete, "/docs/"+escape(docId)+"/access/members/"+escape(userId), nil, nil, nil)
+}
+
+func (c *Client) RevokeDocGroupAccess(ctx context.Context, docId data.Id, groupId data.Id) error {
+	return c.do(ctx, http.MethodDelete, "/docs/"+escape(docId)+"/access/groups/"+escape(groupId), nil, nil, nil)
+}
+
+func (c *Client) Search(ctx context.Context, request model.SearchRequest, page model.PageRequest) (*SearchPage, error) {
+	query := url.Values{}
+	query.Set("q", request.Query)
+	setIfNotEmpty(query, "mode", request.Mode)
+	setIfNotEmpty(query, "lang", request.Lang)
+	setIfNotEmpty(query, "owner", string(request.Owner))
+	setIfNotEmpty(query, "tag", request.Tag)
+	var res SearchPage
+	if err := c.do(ctx, http.MethodGet, "/search", pageQuery(query, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// FindSymbols looks up Go symbols by name prefix; kind may be empty.
+func (c *Client) FindSymbols(ctx context.Context, q string, kind string, page model.PageRequest) (*SymbolPage, error) {
+	query := url.Values{}
+	query.Set("q", q)
+	setIfNotEmpty(query, "kind", kind)
+	var res SymbolPage
+	if err := c.do(ctx, http.MethodGet, "/symbols", pageQuery(query, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func setIfNotEmpty(query url.Values, key string, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"doccer/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Error is an error answered by the server. It matches the model error
+// with the same code, so callers can write
+// errors.Is(err, model.ErrNotFound).
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []model.FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("doccer: %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("doccer: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*model.Error)
+	return ok && e.Code != "" && t.Code == e.Code
+}
+
+func decodeError(resp *http.Response) error {
+	res := &Error{Status: resp.StatusCode}
+	var body struct {
+		Error struct {
+			Code    string             `json:"code"`
+			Message string             `json:"message"`
+			Details []model.FieldError `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error.Code == "" {
+		res.Message = http.StatusText(resp.StatusCode)
+		return res
+	}
+	res.Code = body.Error.Code
+	res.Message = body.Error.Message
+	res.Details = body.Error.Details
+	return res
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"net/http"
+)
+
+func folderPath(folderId data.Id) string {
+	return "/folders/" + escape(folderId)
+}
+
+func (c *Client) CreateFolder(ctx context.Context, folder data.Folder) (*data.Folder, error) {
+	var res data.Folder
+	if err := c.do(ctx, http.MethodPost, "/folders", nil, folder, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) ListFolders(ctx context.Context, page model.PageRequest) (*FolderPage, error) {
+	var res FolderPage
+	if err := c.do(ctx, http.MethodGet, "/folders", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetFolder returns the folder with the first page of its subfolders and a
+// page of its docs.
+func (c *Client) GetFolder(ctx context.Context, folderId data.Id, page model.PageRequest) (*data.FolderContents, error) {
+	var res data.FolderContents
+	if err := c.do(ctx, http.MethodGet, folderPath(folderId), pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListSubfolders returns a page of the subfolders of the folder.
+func (c *Client) ListSubfolders(ctx context.Context, folderId data.Id, page model.PageRequest) (*FolderPage, error) {
+	var res FolderPage
+	if err := c.do(ctx, http.MethodGet, folderPath(folderId)+"/folders", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) RenameFolder(ctx context.Context, folderId data.Id, name string) (*data.Folder, error) {
+	var res data.Folder
+	if err := c.do(ctx, http.MethodPut, folderPath(folderId), nil, data.Folder{Name: name}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MoveFolder moves the folder under parentId, or to the top if it is empty.
+func (c *Client) MoveFolder(ctx context.Context, folderId data.Id, parentId data.Id) (*data.Folder, error) {
+	var res data.Folder
+	err := c.do(ctx, http.MethodPut, folderPath(folderId)+"/parent", nil, model.MoveRequest{FolderId: parentId}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) DeleteFolder(ctx context.Context, folderId data.Id) error {
+	return c.do(ctx, http.MethodDelete, folderPath(folderId), nil, nil, nil)
+}
+
+// ChangeFolderAccess grants access to the folder; DocId of the request is
+// the folder id.
+func (c *Client) ChangeFolderAccess(ctx context.Context, request model.DocAccessRequest) (*data.FolderAcl, error) {
+	var res data.FolderAcl
+	if err := c.do(ctx, http.MethodPost, folderPath(request.DocId)+"/access", nil, request, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetFolderAccess(ctx context.Context, folderId data.Id) (*data.FolderAcl, error) {
+	var res data.FolderAcl
+	if err := c.do(ctx, http.MethodGet, folderPath(folderId)+"/access", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) RevokeFolderMemberAccess(ctx context.Context, folderId data.Id, userId data.Id) error {
+	return c.do(ctx, http.MethodDelete, folderPath(folderId)+"/access/members/"+escape(userId), nil, nil, nil)
+}
+
+func (c *Client) RevokeFolderGroupAccess(ctx context.Context, folderId data.Id, groupId data.Id) error {
+	return c.do(ctx, http.MethodDelete, folderPath(folderId)+"/access/groups/"+escape(groupId), nil, nil, nil)
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"net/http"
+)
+
+func groupPath(groupId data.Id) string {
+	return "/users/groups/" + escape(groupId)
+}
+
+func (c *Client) CreateGroup(ctx context.Context, group data.Group) (*data.Group, error) {
+	var res data.Group
+	if err := c.do(ctx, http.MethodPost, "/users/groups", nil, group, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) EditGroup(ctx context.Context, group data.Group) (*data.Group, error) {
+	var res data.Group
+	if err := c.do(ctx, http.MethodPut, groupPath(group.Id), nil, group, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) DeleteGroup(ctx context.Context, groupId data.Id) error {
+	return c.do(ctx, http.MethodDelete, groupPath(groupId), nil, nil, nil)
+}
+
+func (c *Client) ListMembers(ctx context.Context, groupId data.Id, page model.PageRequest) (*MemberPage, error) {
+	var res MemberPage
+	if err := c.do(ctx, http.MethodGet, groupPath(groupId)+"/members", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) AddMember(ctx context.Context, groupId data.Id, memberId data.Id) error {
+	return c.do(ctx, http.MethodPut, groupPath(groupId)+"/members", nil, model.MemberRequest{MemberId: memberId}, nil)
+}
+
+func (c *Client) RemoveMember(ctx context.Context, groupId data.Id, memberId data.Id) error {
+	return c.do(ctx, http.MethodDelete, groupPath(groupId)+"/members", nil, model.MemberRequest{MemberId: memberId}, nil)
+}
+
+func (c *Client) SetMemberRole(ctx context.Context, groupId data.Id, memberId data.Id, role data.Role) error {
+	request := model.RoleRequest{MemberId: memberId, Role: role}
+	return c.do(ctx, http.MethodPut, groupPath(groupId)+"/roles", nil, request, nil)
+}
+
+func (c *Client) TransferGroupOwnership(ctx context.Context, groupId data.Id, ownerId data.Id) error {
+	return c.do(ctx, http.MethodPut, groupPath(groupId)+"/owner", nil, model.OwnerRequest{OwnerId: ownerId}, nil)
+}
+
+func (c *Client) ListSubgroups(ctx context.Context, groupId data.Id, page model.PageRequest) (*GroupPage, error) {
+	var res GroupPage
+	if err := c.do(ctx, http.MethodGet, groupPath(groupId)+"/subgroups", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) AddSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	request := model.SubgroupRequest{SubgroupId: subgroupId}
+	return c.do(ctx, http.MethodPut, groupPath(groupId)+"/subgroups", nil, request, nil)
+}
+
+func (c *Client) RemoveSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	request := model.SubgroupRequest{SubgroupId: subgroupId}
+	return c.do(ctx, http.MethodDelete, groupPath(groupId)+"/subgroups", nil, request, nil)
+}
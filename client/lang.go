@@ -0,0 +1,20 @@
+package client
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languages maps file extensions to the languages the server lints.
+var languages = map[string]string{
+	".go":  "go",
+	".txt": "Text",
+	".md":  "Text",
+}
+
+// LangOf infers the language of a doc from the extension of its file name.
+// Files with other extensions have no language, as the server refuses the
+// languages it does not lint.
+func LangOf(path string) string {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"net/http"
+)
+
+func orgPath(orgId data.Id) string {
+	return "/orgs/" + escape(orgId)
+}
+
+func (c *Client) CreateOrg(ctx context.Context, org data.Org) (*data.Org, error) {
+	var res data.Org
+	if err := c.do(ctx, http.MethodPost, "/orgs", nil, org, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) ListOrgs(ctx context.Context, page model.PageRequest) (*OrgPage, error) {
+	var res OrgPage
+	if err := c.do(ctx, http.MethodGet, "/orgs", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) GetOrg(ctx context.Context, orgId data.Id) (*data.Org, error) {
+	var res data.Org
+	if err := c.do(ctx, http.MethodGet, orgPath(orgId), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) EditOrg(ctx context.Context, org data.Org) (*data.Org, error) {
+	var res data.Org
+	if err := c.do(ctx, http.MethodPut, orgPath(org.Id), nil, org, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) DeleteOrg(ctx context.Context, orgId data.Id) error {
+	return c.do(ctx, http.MethodDelete, orgPath(orgId), nil, nil, nil)
+}
+
+func (c *Client) ListOrgDocs(ctx context.Context, orgId data.Id, page model.PageRequest) (*DocPage, error) {
+	var res DocPage
+	if err := c.do(ctx, http.MethodGet, orgPath(orgId)+"/docs", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) ListOrgMembers(ctx context.Context, orgId data.Id, page model.PageRequest) (*MemberPage, error) {
+	var res MemberPage
+	if err := c.do(ctx, http.MethodGet, orgPath(orgId)+"/members", pageQuery(nil, page), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) AddOrgMember(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	request := model.OrgMemberRequest{MemberId: memberId, Role: role}
+	return c.do(ctx, http.MethodPut, orgPath(orgId)+"/members", nil, request, nil)
+}
+
+func (c *Client) RemoveOrgMember(ctx context.Context, orgId data.Id, memberId data.Id) error {
+	request := model.OrgMemberRequest{MemberId: memberId}
+	return c.do(ctx, http.MethodDelete, orgPath(orgId)+"/members", nil, request, nil)
+}
+
+func (c *Client) SetOrgMemberRole(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	request := model.OrgMemberRequest{MemberId: memberId, Role: role}
+	return c.do(ctx, http.MethodPut, orgPath(orgId)+"/roles", nil, request, nil)
+}
@@ -0,0 +1,41 @@
+package client
+
+import "doccer/data"
+
+// Pages of listings. Pass Next as the cursor of model.PageRequest to get
+// the following page; it is empty on the last one.
+
+type DocPage struct {
+	Items []data.Doc `json:"items"`
+	Next  string     `json:"next,omitempty"`
+}
+
+type SearchPage struct {
+	Items []data.SearchResult `json:"items"`
+	Next  string              `json:"next,omitempty"`
+}
+
+type SymbolPage struct {
+	Items []data.Symbol `json:"items"`
+	Next  string        `json:"next,omitempty"`
+}
+
+type MemberPage struct {
+	Items []data.Member `json:"items"`
+	Next  string        `json:"next,omitempty"`
+}
+
+type GroupPage struct {
+	Items []data.Group `json:"items"`
+	Next  string       `json:"next,omitempty"`
+}
+
+type OrgPage struct {
+	Items []data.Org `json:"items"`
+	Next  string     `json:"next,omitempty"`
+}
+
+type FolderPage struct {
+	Items []data.Folder `json:"items"`
+	Next  string        `json:"next,omitempty"`
+}
@@ -0,0 +1,436 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"doccer/data"
+	"doccer/model"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestName is the file in a synced directory that maps its files to
+// docs. It is never uploaded, like every other file starting with a dot.
+const ManifestName = ".doccer-sync.json"
+
+// ConflictSuffix ends the copies of remote docs written next to local files
+// that conflict with them.
+const ConflictSuffix = ".remote"
+
+// Manifest records what both sides looked like after the last sync, which
+// tells local changes from remote ones.
+type Manifest struct {
+	FolderId data.Id                   `json:"folderId,omitempty"`
+	Files    map[string]*ManifestEntry `json:"files"`
+}
+
+type ManifestEntry struct {
+	DocId   data.Id `json:"docId"`
+	Version int     `json:"version"`
+	// Hash is the SHA-256 of the text both sides had at the last sync.
+	Hash string `json:"hash"`
+	// Conflict is the version of the doc written to the conflict copy.
+	// Removing the copy resolves the conflict in favour of the local file.
+	Conflict int `json:"conflict,omitempty"`
+}
+
+type SyncOptions struct {
+	// FolderId is the doccer folder mirrored by the directory: new local
+	// files are created in it and its new docs are downloaded. It is kept in
+	// the manifest, so later syncs may leave it empty. Without a folder only
+	// the docs in the manifest are synced and new files are created outside
+	// any folder.
+	FolderId data.Id
+	// DryRun reports the changes without making them.
+	DryRun bool
+}
+
+type SyncAction string
+
+const (
+	SyncCreated       SyncAction = "created"
+	SyncUploaded      SyncAction = "uploaded"
+	SyncDownloaded    SyncAction = "downloaded"
+	SyncDeletedLocal  SyncAction = "deleted local"
+	SyncDeletedRemote SyncAction = "deleted remote"
+	SyncConflict      SyncAction = "conflict"
+)
+
+type SyncChange struct {
+	Path   string     `json:"path"`
+	DocId  data.Id    `json:"docId"`
+	Action SyncAction `json:"action"`
+}
+
+// Sync mirrors the files of dir and the docs listed in its manifest, or in
+// the synced folder, both ways. A side that changed since the last sync
+// wins; when both changed the remote text is written next to the local
+// file with ConflictSuffix and neither side is overwritten. A deletion
+// loses to an edit on the other side.
+func (c *Client) Sync(ctx context.Context, dir string, opts SyncOptions) ([]SyncChange, error) {
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FolderId != "" {
+		manifest.FolderId = opts.FolderId
+	}
+	local, err := scanSyncDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &syncer{client: c, dir: dir, dryRun: opts.DryRun, manifest: manifest, local: local}
+
+	if err := s.syncTracked(ctx); err != nil {
+		return s.changes, err
+	}
+	if err := s.downloadNew(ctx); err != nil {
+		return s.changes, err
+	}
+	if err := s.uploadNew(ctx); err != nil {
+		return s.changes, err
+	}
+	return s.changes, s.save()
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	manifest := Manifest{Files: map[string]*ManifestEntry{}}
+	b, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]*ManifestEntry{}
+	}
+	return &manifest, nil
+}
+
+type syncer struct {
+	client   *Client
+	dir      string
+	dryRun   bool
+	manifest *Manifest
+	// local maps the slash-separated paths of the files to their hashes.
+	local   map[string]string
+	changes []SyncChange
+}
+
+// scanSyncDir hashes the files of dir, skipping hidden files and
+// directories and conflict copies.
+func scanSyncDir(dir string) (map[string]string, error) {
+	local := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasSuffix(path, ConflictSuffix) {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		local[filepath.ToSlash(rel)] = hashText(b)
+		return nil
+	})
+	return local, err
+}
+
+func hashText(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *syncer) osPath(path string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path))
+}
+
+// record notes a change and saves the manifest, so an interrupted sync
+// does not upload the same file twice.
+func (s *syncer) record(path string, docId data.Id, action SyncAction) error {
+	s.changes = append(s.changes, SyncChange{Path: path, DocId: docId, Action: action})
+	return s.save()
+}
+
+func (s *syncer) save() error {
+	if s.dryRun {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, ManifestName+".tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, ManifestName))
+}
+
+func sortedPaths(m map[string]*ManifestEntry) []string {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// syncTracked syncs the files in the manifest.
+func (s *syncer) syncTracked(ctx context.Context) error {
+	for _, path := range sortedPaths(s.manifest.Files) {
+		if err := s.syncFile(ctx, path, s.manifest.Files[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncer) syncFile(ctx context.Context, path string, entry *ManifestEntry) error {
+	doc, err := s.client.GetDoc(ctx, entry.DocId)
+	if errors.Is(err, model.ErrNotFound) {
+		doc = nil
+	} else if err != nil {
+		return err
+	}
+	localHash, exists := s.local[path]
+	localChanged := exists && localHash != entry.Hash
+
+	switch {
+	case doc == nil && !exists:
+		delete(s.manifest.Files, path)
+		return s.save()
+	case doc == nil && localChanged:
+		delete(s.manifest.Files, path)
+		return s.create(ctx, path)
+	case doc == nil:
+		if !s.dryRun {
+			if err := os.Remove(s.osPath(path)); err != nil {
+				return err
+			}
+		}
+		delete(s.manifest.Files, path)
+		delete(s.local, path)
+		return s.record(path, entry.DocId, SyncDeletedLocal)
+	case !exists && doc.Version != entry.Version:
+		return s.download(path, entry, doc)
+	case !exists:
+		if !s.dryRun {
+			if err := s.client.DeleteDoc(ctx, doc.Id); err != nil {
+				return err
+			}
+		}
+		delete(s.manifest.Files, path)
+		return s.record(path, doc.Id, SyncDeletedRemote)
+	}
+
+	if entry.Conflict != 0 {
+		if _, err := os.Stat(s.osPath(path) + ConflictSuffix); err == nil {
+			if doc.Version == entry.Conflict {
+				s.changes = append(s.changes, SyncChange{Path: path, DocId: doc.Id, Action: SyncConflict})
+				return nil
+			}
+			return s.conflict(path, entry, doc)
+		}
+		// the copy is gone: the local file is the resolution
+		entry.Version, entry.Conflict = entry.Conflict, 0
+		localChanged = true
+	}
+
+	remoteChanged := doc.Version != entry.Version
+	switch {
+	case localChanged && !remoteChanged:
+		return s.upload(ctx, path, entry, doc)
+	case remoteChanged && !localChanged:
+		return s.download(path, entry, doc)
+	case remoteChanged && hashText([]byte(doc.Text)) == localHash:
+		// both sides made the same change
+		entry.Version, entry.Hash = doc.Version, localHash
+		return s.save()
+	case remoteChanged:
+		return s.conflict(path, entry, doc)
+	}
+	return nil
+}
+
+func (s *syncer) upload(ctx context.Context, path string, entry *ManifestEntry, doc *data.Doc) error {
+	text, err := os.ReadFile(s.osPath(path))
+	if err != nil {
+		return err
+	}
+	if s.dryRun {
+		return s.record(path, doc.Id, SyncUploaded)
+	}
+	newText := string(text)
+	res, err := s.client.EditDoc(ctx, model.DocEdit{Id: doc.Id, Text: &newText, Version: entry.Version})
+	if errors.Is(err, model.ErrVersionConflict) {
+		// edited after we read it
+		if doc, err = s.client.GetDoc(ctx, doc.Id); err != nil {
+			return err
+		}
+		return s.conflict(path, entry, doc)
+	}
+	if err != nil {
+		return err
+	}
+	entry.Version, entry.Hash = res.Version, hashText(text)
+	return s.record(path, doc.Id, SyncUploaded)
+}
+
+func (s *syncer) download(path string, entry *ManifestEntry, doc *data.Doc) error {
+	if !s.dryRun {
+		if err := writeSyncFile(s.osPath(path), doc.Text); err != nil {
+			return err
+		}
+		if entry.Conflict != 0 {
+			_ = os.Remove(s.osPath(path) + ConflictSuffix)
+		}
+	}
+	entry.Version, entry.Hash, entry.Conflict = doc.Version, hashText([]byte(doc.Text)), 0
+	s.manifest.Files[path] = entry
+	return s.record(path, doc.Id, SyncDownloaded)
+}
+
+// conflict writes the remote text next to the local file and leaves both
+// sides alone until the copy is removed.
+func (s *syncer) conflict(path string, entry *ManifestEntry, doc *data.Doc) error {
+	if !s.dryRun {
+		if err := writeSyncFile(s.osPath(path)+ConflictSuffix, doc.Text); err != nil {
+			return err
+		}
+	}
+	entry.Conflict = doc.Version
+	s.manifest.Files[path] = entry
+	return s.record(path, doc.Id, SyncConflict)
+}
+
+func (s *syncer) create(ctx context.Context, path string) error {
+	if s.dryRun {
+		return s.record(path, "", SyncCreated)
+	}
+	text, err := os.ReadFile(s.osPath(path))
+	if err != nil {
+		return err
+	}
+	doc, err := s.client.CreateDoc(ctx, data.Doc{
+		Text:     string(text),
+		Lang:     LangOf(path),
+		Title:    filepath.Base(filepath.FromSlash(path)),
+		FolderId: s.manifest.FolderId,
+	})
+	if err != nil {
+		return err
+	}
+	s.manifest.Files[path] = &ManifestEntry{DocId: doc.Id, Version: doc.Version, Hash: hashText(text)}
+	return s.record(path, doc.Id, SyncCreated)
+}
+
+func writeSyncFile(path string, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0644)
+}
+
+// downloadNew downloads the docs of the synced folder that are not in the
+// manifest yet, named after their titles.
+func (s *syncer) downloadNew(ctx context.Context) error {
+	if s.manifest.FolderId == "" {
+		return nil
+	}
+	tracked := map[data.Id]bool{}
+	for _, entry := range s.manifest.Files {
+		tracked[entry.DocId] = true
+	}
+
+	page := model.PageRequest{Summary: true}
+	for {
+		contents, err := s.client.GetFolder(ctx, s.manifest.FolderId, page)
+		if err != nil {
+			return err
+		}
+		for _, summary := range contents.Docs {
+			if tracked[summary.Id] {
+				continue
+			}
+			doc, err := s.client.GetDoc(ctx, summary.Id)
+			if err != nil {
+				return err
+			}
+			if err := s.downloadNewDoc(doc); err != nil {
+				return err
+			}
+		}
+		if contents.Next == "" {
+			return nil
+		}
+		page.Cursor = contents.Next
+	}
+}
+
+func (s *syncer) downloadNewDoc(doc *data.Doc) error {
+	path := syncFileName(doc)
+	if _, ok := s.manifest.Files[path]; ok {
+		path += "-" + string(doc.Id)
+	}
+	entry := &ManifestEntry{DocId: doc.Id}
+	localHash, exists := s.local[path]
+	switch {
+	case !exists:
+		return s.download(path, entry, doc)
+	case localHash == hashText([]byte(doc.Text)):
+		entry.Version, entry.Hash = doc.Version, localHash
+		s.manifest.Files[path] = entry
+		return s.save()
+	}
+	// an untracked file of the same name: the local file wins once the
+	// conflict copy is removed
+	return s.conflict(path, entry, doc)
+}
+
+// syncFileName names the file of a doc after its title.
+func syncFileName(doc *data.Doc) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(doc.Title)
+	if name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ConflictSuffix) {
+		name = "doc-" + string(doc.Id) + name
+	}
+	return name
+}
+
+// uploadNew creates docs for the local files that are not in the manifest.
+func (s *syncer) uploadNew(ctx context.Context) error {
+	paths := make([]string, 0, len(s.local))
+	for path := range s.local {
+		if _, ok := s.manifest.Files[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := s.create(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials are kept between runs in the user config directory. Only the
+// token is stored, never the password.
+type credentials struct {
+	Server string `json:"server"`
+	Login  string `json:"login"`
+	Token  string `json:"token"`
+}
+
+func credentialsPath() (string, error) {
+	if path := os.Getenv("DOCCER_CREDENTIALS"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "doccer", "credentials.json"), nil
+}
+
+func loadCredentials() (*credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var creds credentials
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &creds, nil
+}
+
+func saveCredentials(creds *credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+func loginFlags(name string, args []string) (string, string, error) {
+	fs := newFlagSet(name)
+	password := fs.String("password", "", "password (default $DOCCER_PASSWORD or read from stdin)")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return "", "", err
+	}
+	if *password == "" {
+		*password = os.Getenv("DOCCER_PASSWORD")
+	}
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", "", err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	return positional[0], *password, nil
+}
+
+func register(ctx context.Context, c *cli, args []string) error {
+	login, password, err := loginFlags("register", args)
+	if err != nil {
+		return err
+	}
+	user, err := c.client.Register(ctx, login, password)
+	if err != nil {
+		return err
+	}
+	if err := c.logIn(ctx, login, password); err != nil {
+		return err
+	}
+	return c.print(user, func(w io.Writer) {
+		fmt.Fprintf(w, "Registered %s with id %s\n", user.Login, user.Id)
+	})
+}
+
+func login(ctx context.Context, c *cli, args []string) error {
+	login, password, err := loginFlags("login", args)
+	if err != nil {
+		return err
+	}
+	if err := c.logIn(ctx, login, password); err != nil {
+		return err
+	}
+	return c.print(c.creds, func(w io.Writer) {
+		fmt.Fprintf(w, "Logged in to %s as %s\n", c.server, login)
+	})
+}
+
+func (c *cli) logIn(ctx context.Context, login string, password string) error {
+	res, err := c.client.Login(ctx, login, password)
+	if err != nil {
+		return err
+	}
+	c.creds = &credentials{Server: c.server, Login: login, Token: string(res.Token)}
+	return saveCredentials(c.creds)
+}
+
+func logout(ctx context.Context, c *cli, args []string) error {
+	if _, err := parseArgs(newFlagSet("logout"), args, 0); err != nil {
+		return err
+	}
+	if c.creds.Token != "" && c.creds.Server == c.server {
+		// the token is forgotten locally even if the server is unreachable
+		if err := c.client.Logout(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "doccer: logout:", err)
+		}
+	}
+	if err := saveCredentials(&credentials{}); err != nil {
+		return err
+	}
+	return c.print(struct{}{}, func(w io.Writer) {
+		fmt.Fprintln(w, "Logged out")
+	})
+}
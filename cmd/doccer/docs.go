@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"doccer/client"
+	"doccer/data"
+	"doccer/model"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// uninspected is the linter status of a doc the linter has not finished.
+const uninspected = "No inspection"
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("doccer "+name, flag.ContinueOnError)
+}
+
+func push(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("push")
+	id := fs.String("id", "", "replace the doc with this id instead of creating one")
+	lang := fs.String("lang", "", "language of the doc (default inferred from the file extension)")
+	title := fs.String("title", "", "title of the doc (default the file name for a new doc)")
+	access := fs.String("access", "", "access of everyone else: none, read, edit or absolute (default none for a new doc)")
+	wait := fs.Bool("wait", true, "wait for the linter and print its result")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the linter")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag of the doc, may be repeated")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	path := positional[0]
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if *lang == "" {
+		*lang = client.LangOf(path)
+	}
+	var level *data.AccessLevel
+	if *access != "" {
+		parsed, err := data.ParseAccessLevel(*access)
+		if err != nil {
+			return err
+		}
+		level = &parsed
+	}
+
+	var res *data.Doc
+	if *id == "" {
+		doc := data.Doc{Text: string(text), Lang: *lang, Title: *title, Tags: tags}
+		if doc.Title == "" {
+			doc.Title = filepath.Base(path)
+		}
+		if level != nil {
+			doc.Access = *level
+		}
+		res, err = c.client.CreateDoc(ctx, doc)
+	} else {
+		// fields not given keep their values
+		newText := string(text)
+		edit := model.DocEdit{Id: data.Id(*id), Text: &newText, Access: level}
+		if *lang != "" {
+			edit.Lang = lang
+		}
+		if *title != "" {
+			edit.Title = title
+		}
+		if len(tags) > 0 {
+			edit.Tags = (*[]string)(&tags)
+		}
+		res, err = c.client.EditDoc(ctx, edit)
+	}
+	if err != nil {
+		return err
+	}
+	if *wait {
+		if res, err = waitForLinter(ctx, c, res.Id, uninspected, *timeout); err != nil {
+			return err
+		}
+	}
+	return c.print(res, func(w io.Writer) {
+		fmt.Fprintf(w, "Pushed %s as doc %s (%s)\n", path, res.Id, res.Lang)
+		if *wait {
+			printLinterStatus(w, res)
+		}
+	})
+}
+
+func pull(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("pull")
+	output := fs.String("o", "", "write the text to this file instead of stdout")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	doc, err := c.client.GetDoc(ctx, data.Id(positional[0]))
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(doc.Text), 0644); err != nil {
+			return err
+		}
+		if !c.json {
+			return nil
+		}
+	}
+	return c.print(doc, func(w io.Writer) {
+		fmt.Fprint(w, doc.Text)
+	})
+}
+
+func ls(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("ls")
+	var filter model.DocFilter
+	fs.StringVar(&filter.Tag, "tag", "", "only docs with this tag")
+	fs.StringVar(&filter.Lang, "lang", "", "only docs in this language")
+	fs.StringVar(&filter.Title, "title", "", "only docs whose title contains this")
+	fs.Func("created-after", "only docs created after this RFC 3339 time", timeFlag(&filter.CreatedAfter))
+	fs.Func("created-before", "only docs created before this RFC 3339 time", timeFlag(&filter.CreatedBefore))
+	fs.Func("updated-after", "only docs updated after this RFC 3339 time", timeFlag(&filter.UpdatedAfter))
+	fs.Func("updated-before", "only docs updated before this RFC 3339 time", timeFlag(&filter.UpdatedBefore))
+	editedBy := fs.String("edited-by", "", "only docs last edited by this user id")
+	fs.StringVar(&filter.Sort, "sort", "", "sort by title, created_at or updated_at")
+	fs.BoolVar(&filter.Desc, "desc", false, "sort in descending order")
+	limit := fs.Int("limit", 50, "docs per page")
+	all := fs.Bool("all", false, "list every page instead of the first one")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	filter.EditedBy = data.Id(*editedBy)
+
+	page := model.PageRequest{Limit: *limit, Summary: true}
+	var docs []data.Doc
+	for {
+		res, err := c.client.ListDocs(ctx, filter, page)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, res.Items...)
+		if !*all || res.Next == "" {
+			break
+		}
+		page.Cursor = res.Next
+	}
+	if docs == nil {
+		docs = []data.Doc{}
+	}
+	return c.print(docs, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTITLE\tLANG\tACCESS\tUPDATED\tTAGS")
+		for _, doc := range docs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", doc.Id, doc.Title, doc.Lang, doc.Access,
+				doc.UpdatedAt.Local().Format("2006-01-02 15:04"), strings.Join(doc.Tags, ","))
+		}
+		_ = tw.Flush()
+	})
+}
+
+func share(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("share")
+	user := fs.String("user", "", "id of the user to share with")
+	group := fs.String("group", "", "id of the group to share with")
+	access := fs.String("access", "read", "access to grant: none, read, edit or absolute")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	request := model.DocAccessRequest{DocId: data.Id(positional[0])}
+	switch {
+	case *user != "" && *group == "":
+		request.Type, request.ItemId = model.MemberAccess, data.Id(*user)
+	case *group != "" && *user == "":
+		request.Type, request.ItemId = model.GroupAccess, data.Id(*group)
+	default:
+		return errors.New("share: give exactly one of -user and -group")
+	}
+	if request.Access, err = data.ParseAccessLevel(*access); err != nil {
+		return err
+	}
+	if _, err := c.client.ChangeDocAccess(ctx, request); err != nil {
+		return err
+	}
+	acl, err := c.client.GetDocAccess(ctx, request.DocId)
+	if err != nil {
+		return err
+	}
+	return c.print(acl, func(w io.Writer) {
+		fmt.Fprintf(w, "Doc %s: everyone %s\n", acl.DocId, acl.Public)
+		for _, member := range acl.Members {
+			fmt.Fprintf(w, "  user %s (%s): %s\n", member.Login, member.UserId, member.Access)
+		}
+		for _, group := range acl.Groups {
+			fmt.Fprintf(w, "  group %s (%s): %s\n", group.Name, group.GroupId, group.Access)
+		}
+	})
+}
+
+func lint(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("lint")
+	wait := fs.Bool("wait", false, "wait for the linter and print its result")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the linter")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	docId := data.Id(positional[0])
+	doc, err := c.client.GetDoc(ctx, docId)
+	if err != nil {
+		return err
+	}
+	if err := c.client.LaunchLinter(ctx, docId); err != nil {
+		return err
+	}
+	if *wait {
+		if doc, err = waitForLinter(ctx, c, docId, doc.LinterStatus, *timeout); err != nil {
+			return err
+		}
+	}
+	return c.print(doc, func(w io.Writer) {
+		if !*wait {
+			fmt.Fprintf(w, "Linter started for doc %s\n", docId)
+			return
+		}
+		printLinterStatus(w, doc)
+	})
+}
+
+// waitForLinter polls the doc until its linter status differs from
+// previous or the timeout passes. The server does not tell when a run
+// finishes, so a run that reports the same status as before is only seen
+// once the timeout passes.
+func waitForLinter(ctx context.Context, c *cli, docId data.Id, previous string, timeout time.Duration) (*data.Doc, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	var doc *data.Doc
+	for {
+		select {
+		case <-ctx.Done():
+			if doc == nil {
+				return nil, ctx.Err()
+			}
+			return doc, nil
+		case <-ticker.C:
+		}
+		res, err := c.client.GetDoc(ctx, docId)
+		if err != nil {
+			if doc != nil && errors.Is(err, context.DeadlineExceeded) {
+				return doc, nil
+			}
+			return nil, err
+		}
+		doc = res
+		if doc.LinterStatus != previous {
+			return doc, nil
+		}
+	}
+}
+
+func printLinterStatus(w io.Writer, doc *data.Doc) {
+	fmt.Fprintln(w, "Linter:")
+	for _, line := range strings.Split(doc.LinterStatus, "\n") {
+		fmt.Fprintln(w, "  "+line)
+	}
+}
+
+// timeFlag parses an RFC 3339 flag value into t.
+func timeFlag(t *time.Time) func(string) error {
+	return func(s string) error {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		*t = v
+		return nil
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/model"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+var groupCommands = map[string]struct {
+	args int
+	run  func(ctx context.Context, c *cli, args []string) error
+}{
+	"create":    {1, createGroup},
+	"rename":    {2, renameGroup},
+	"rm":        {1, deleteGroup},
+	"members":   {1, listMembers},
+	"add":       {2, addMember},
+	"remove":    {2, removeMember},
+	"role":      {3, setMemberRole},
+	"subgroups": {1, listSubgroups},
+}
+
+const groupUsage = `usage:
+  doccer group create <name>
+  doccer group rename <group id> <name>
+  doccer group rm <group id>
+  doccer group members <group id>
+  doccer group add <group id> <user id>
+  doccer group remove <group id> <user id>
+  doccer group role <group id> <user id> member|admin|owner
+  doccer group subgroups <group id>`
+
+func group(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New(groupUsage)
+	}
+	sub, ok := groupCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown group command %q\n%s", args[0], groupUsage)
+	}
+	positional, err := parseArgs(newFlagSet("group "+args[0]), args[1:], sub.args)
+	if err != nil {
+		return err
+	}
+	return sub.run(ctx, c, positional)
+}
+
+func printGroup(c *cli, group *data.Group) error {
+	return c.print(group, func(w io.Writer) {
+		fmt.Fprintf(w, "Group %s: %s\n", group.Id, group.Name)
+	})
+}
+
+func createGroup(ctx context.Context, c *cli, args []string) error {
+	group, err := c.client.CreateGroup(ctx, data.Group{Name: args[0]})
+	if err != nil {
+		return err
+	}
+	return printGroup(c, group)
+}
+
+func renameGroup(ctx context.Context, c *cli, args []string) error {
+	group, err := c.client.EditGroup(ctx, data.Group{Id: data.Id(args[0]), Name: args[1]})
+	if err != nil {
+		return err
+	}
+	return printGroup(c, group)
+}
+
+func deleteGroup(ctx context.Context, c *cli, args []string) error {
+	if err := c.client.DeleteGroup(ctx, data.Id(args[0])); err != nil {
+		return err
+	}
+	return c.print(struct{}{}, func(w io.Writer) {
+		fmt.Fprintf(w, "Deleted group %s\n", args[0])
+	})
+}
+
+func listMembers(ctx context.Context, c *cli, args []string) error {
+	var members []data.Member
+	page := model.PageRequest{}
+	for {
+		res, err := c.client.ListMembers(ctx, data.Id(args[0]), page)
+		if err != nil {
+			return err
+		}
+		members = append(members, res.Items...)
+		if res.Next == "" {
+			break
+		}
+		page.Cursor = res.Next
+	}
+	if members == nil {
+		members = []data.Member{}
+	}
+	return c.print(members, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tLOGIN\tROLE")
+		for _, member := range members {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", member.Id, member.Login, member.Role)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func addMember(ctx context.Context, c *cli, args []string) error {
+	if err := c.client.AddMember(ctx, data.Id(args[0]), data.Id(args[1])); err != nil {
+		return err
+	}
+	return c.print(struct{}{}, func(w io.Writer) {
+		fmt.Fprintf(w, "Added user %s to group %s\n", args[1], args[0])
+	})
+}
+
+func removeMember(ctx context.Context, c *cli, args []string) error {
+	if err := c.client.RemoveMember(ctx, data.Id(args[0]), data.Id(args[1])); err != nil {
+		return err
+	}
+	return c.print(struct{}{}, func(w io.Writer) {
+		fmt.Fprintf(w, "Removed user %s from group %s\n", args[1], args[0])
+	})
+}
+
+func setMemberRole(ctx context.Context, c *cli, args []string) error {
+	role, err := data.ParseRole(args[2])
+	if err != nil {
+		return err
+	}
+	if err := c.client.SetMemberRole(ctx, data.Id(args[0]), data.Id(args[1]), role); err != nil {
+		return err
+	}
+	return c.print(struct{}{}, func(w io.Writer) {
+		fmt.Fprintf(w, "User %s is now %s of group %s\n", args[1], role, args[0])
+	})
+}
+
+func listSubgroups(ctx context.Context, c *cli, args []string) error {
+	var groups []data.Group
+	page := model.PageRequest{}
+	for {
+		res, err := c.client.ListSubgroups(ctx, data.Id(args[0]), page)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, res.Items...)
+		if res.Next == "" {
+			break
+		}
+		page.Cursor = res.Next
+	}
+	if groups == nil {
+		groups = []data.Group{}
+	}
+	return c.print(groups, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME")
+		for _, group := range groups {
+			fmt.Fprintf(tw, "%s\t%s\n", group.Id, group.Name)
+		}
+		_ = tw.Flush()
+	})
+}
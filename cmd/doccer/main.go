@@ -0,0 +1,186 @@
+// Command doccer pushes, pulls and shares docs from the terminal.
+//
+// Usage:
+//
+//	doccer [-server URL] [-json] <command> [arguments]
+//
+// Run `doccer help` for the list of commands.
+package main
+
+import (
+	"context"
+	"doccer/client"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const defaultServer = "http://localhost:8080"
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"register": {"register <login> [-password P]", register},
+	"login":    {"login <login> [-password P]", login},
+	"logout":   {"logout", logout},
+	"push":     {"push <file> [-id ID] [-lang L] [-title T] [-tag T]... [-access A] [-wait=false]", push},
+	"pull":     {"pull <id> [-o file]", pull},
+	"ls":       {"ls [-tag T] [-lang L] [-title T] [-sort S] [-desc] [-limit N] [-all]", ls},
+	"share":    {"share <id> (-user ID | -group ID) -access none|read|edit|absolute", share},
+	"lint":     {"lint <id> [-wait] [-timeout D]", lint},
+	"sync":     {"sync [dir] [-folder ID] [-dry-run]", sync},
+	"group":    {"group create|rename|rm|members|add|remove|role|subgroups ...", group},
+}
+
+// cli is the state shared by the commands.
+type cli struct {
+	server string
+	json   bool
+	out    io.Writer
+	creds  *credentials
+	client *client.Client
+}
+
+func main() {
+	server := flag.String("server", "", "doccer server URL (default $DOCCER_SERVER, the server logged in to or "+defaultServer+")")
+	jsonOut := flag.Bool("json", false, "print results as JSON")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "doccer: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	c, err := newCli(*server, *jsonOut)
+	if err == nil {
+		err = cmd.run(context.Background(), c, flag.Args()[1:])
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: doccer [-server URL] [-json] <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nflags:")
+	flag.PrintDefaults()
+}
+
+func newCli(server string, jsonOut bool) (*cli, error) {
+	creds, err := loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if server == "" {
+		server = os.Getenv("DOCCER_SERVER")
+	}
+	if server == "" {
+		server = creds.Server
+	}
+	if server == "" {
+		server = defaultServer
+	}
+
+	var opts []client.Option
+	if creds.Server == server && creds.Token != "" {
+		opts = append(opts, client.WithToken(creds.Token))
+	}
+	return &cli{
+		server: server,
+		json:   jsonOut,
+		out:    os.Stdout,
+		creds:  creds,
+		client: client.NewClient(server, opts...),
+	}, nil
+}
+
+// print writes v as JSON in JSON mode and calls human otherwise.
+func (c *cli) print(v interface{}, human func(w io.Writer)) error {
+	if c.json {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+	human(c.out)
+	return nil
+}
+
+func printError(err error) {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		fmt.Fprintln(os.Stderr, "doccer:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "doccer:", apiErr.Message)
+	for _, detail := range apiErr.Details {
+		if detail.Field != "" {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", detail.Field, detail.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s\n", detail.Message)
+		}
+	}
+	if apiErr.Status == 401 {
+		fmt.Fprintln(os.Stderr, "run `doccer login` to log in")
+	}
+}
+
+// parseArgs parses flags that may come before, between or after the
+// positional arguments and returns the latter. A negative count accepts
+// any number of them.
+func parseArgs(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var res []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		res = append(res, args[0])
+		args = args[1:]
+	}
+	if positional >= 0 && len(res) != positional {
+		return nil, fmt.Errorf("%s: expected %d argument(s), got %d", fs.Name(), positional, len(res))
+	}
+	return res, nil
+}
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"doccer/client"
+	"doccer/data"
+	"fmt"
+	"io"
+)
+
+func sync(ctx context.Context, c *cli, args []string) error {
+	fs := newFlagSet("sync")
+	folder := fs.String("folder", "", "id of the doccer folder mirrored by the directory (remembered in its manifest)")
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	dir := "."
+	positional, err := parseArgs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	switch len(positional) {
+	case 0:
+	case 1:
+		dir = positional[0]
+	default:
+		return fmt.Errorf("sync: expected at most 1 argument, got %d", len(positional))
+	}
+
+	changes, err := c.client.Sync(ctx, dir, client.SyncOptions{FolderId: data.Id(*folder), DryRun: *dryRun})
+	if changes == nil {
+		changes = []client.SyncChange{}
+	}
+	printErr := c.print(changes, func(w io.Writer) {
+		conflicts := 0
+		for _, change := range changes {
+			fmt.Fprintf(w, "%-15s %s", change.Action, change.Path)
+			if change.DocId != "" {
+				fmt.Fprintf(w, " (doc %s)", change.DocId)
+			}
+			fmt.Fprintln(w)
+			if change.Action == client.SyncConflict {
+				conflicts++
+			}
+		}
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "Up to date")
+		}
+		if conflicts > 0 {
+			fmt.Fprintf(w, "%d conflict(s): merge the %s copies into the files and remove the copies, then sync again\n",
+				conflicts, client.ConflictSuffix)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return printErr
+}
@@ -0,0 +1,339 @@
+// Package config loads the server settings. Defaults are overridden by the
+// YAML file, then by DOCCER_* environment variables, then by flags.
+//
+// Every setting has a path made of its YAML keys, e.g. database.max_open_conns,
+// which is set by the DOCCER_DATABASE_MAX_OPEN_CONNS variable and the
+// -database.max_open_conns flag. The file is given by -config or
+// DOCCER_CONFIG.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Http      HttpConfig      `yaml:"http"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Workers   WorkersConfig   `yaml:"workers"`
+	Linter    LinterConfig    `yaml:"linter"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Quotas    QuotaConfig     `yaml:"quotas"`
+	Limits    LimitsConfig    `yaml:"limits"`
+}
+
+type HttpConfig struct {
+	Addr           string        `yaml:"addr"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes"`
+	// MetricsAddr is the internal listener of /metrics, off if empty.
+	MetricsAddr string `yaml:"metrics_addr"`
+	// ShutdownTimeout bounds the wait for running requests on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// PasswordFile holds the password instead of Password.
+	PasswordFile    string        `yaml:"password_file"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// ClearOnStart deletes all data when the server starts.
+	ClearOnStart bool `yaml:"clear_on_start"`
+	// ConnectTimeout bounds the wait for the database on start.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+type AuthConfig struct {
+	JwtSecret string `yaml:"jwt_secret"`
+	// JwtSecretFile holds the secret instead of JwtSecret.
+	JwtSecretFile string        `yaml:"jwt_secret_file"`
+	TokenTTL      time.Duration `yaml:"token_ttl"`
+	// Admins are the logins allowed into /debug. They cannot be registered;
+	// the server creates them with AdminPassword on start.
+	Admins        []string `yaml:"admins"`
+	AdminPassword string   `yaml:"admin_password"`
+	// AdminPasswordFile holds the password instead of AdminPassword.
+	AdminPasswordFile string `yaml:"admin_password_file"`
+}
+
+type WorkersConfig struct {
+	// Save is the number of workers storing linter results.
+	Save   int `yaml:"save"`
+	Linter int `yaml:"linter"`
+	// DrainTimeout bounds the wait for queued lint jobs on shutdown.
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	// SaveTimeout bounds the storing of a lint result.
+	SaveTimeout time.Duration `yaml:"save_timeout"`
+}
+
+type LinterConfig struct {
+	// Languages maps doc languages to linters: staticcheck, stub or none.
+	// The file adds to the default languages; none turns one off.
+	Languages map[string]string `yaml:"languages"`
+	// Staticcheck is the path of the staticcheck binary.
+	Staticcheck string        `yaml:"staticcheck"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error. SIGHUP rereads it.
+	Level string `yaml:"level"`
+	// Format is text or json.
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	// Endpoint is the host:port of an OTLP/HTTP collector; tracing is off
+	// if it is empty.
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// SampleRatio is the share of new traces that are recorded.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name"`
+}
+
+// RateLimitConfig sets the token buckets of every user, or of every client
+// IP for anonymous requests.
+type RateLimitConfig struct {
+	// Writes are the requests that change data.
+	Writes BucketConfig `yaml:"writes"`
+	// Lint are the requests that queue a linter run.
+	Lint BucketConfig `yaml:"lint"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry,
+	// the one added by the proxy.
+	TrustProxy bool `yaml:"trust_proxy"`
+}
+
+// BucketConfig is a token bucket refilled with PerMinute tokens a minute
+// and holding up to Burst; a zero PerMinute turns the limit off.
+type BucketConfig struct {
+	PerMinute float64 `yaml:"per_minute"`
+	Burst     int     `yaml:"burst"`
+}
+
+// QuotaConfig bounds the docs authored by every user; zero is unlimited.
+type QuotaConfig struct {
+	MaxDocs int `yaml:"max_docs"`
+	// MaxBytes bounds the total size of the doc texts.
+	MaxBytes int `yaml:"max_bytes"`
+	// The anonymous quotas bound the docs created without a token, all of
+	// them together.
+	AnonymousMaxDocs  int `yaml:"anonymous_max_docs"`
+	AnonymousMaxBytes int `yaml:"anonymous_max_bytes"`
+}
+
+// LimitsConfig bounds the size of requests and docs; zero is unlimited.
+type LimitsConfig struct {
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// MaxDocBytes bounds the text of a doc, the others its title and
+	// description.
+	MaxDocBytes         int `yaml:"max_doc_bytes"`
+	MaxTitleBytes       int `yaml:"max_title_bytes"`
+	MaxDescriptionBytes int `yaml:"max_description_bytes"`
+}
+
+const (
+	LogText = "text"
+	LogJson = "json"
+)
+
+const (
+	LinterStaticcheck = "staticcheck"
+	LinterStub        = "stub"
+	LinterNone        = "none"
+)
+
+func Default() Config {
+	return Config{
+		Http: HttpConfig{
+			Addr:            ":8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     time.Minute,
+			MaxHeaderBytes:  1 << 20,
+			MetricsAddr:     ":9090",
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "db",
+			Port:            5432,
+			User:            "postgres",
+			Name:            "postgres",
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+		},
+		Auth: AuthConfig{
+			TokenTTL: 24 * time.Hour,
+		},
+		Workers: WorkersConfig{
+			Save:         10,
+			Linter:       10,
+			DrainTimeout: 30 * time.Second,
+			SaveTimeout:  10 * time.Second,
+		},
+		Linter: LinterConfig{
+			Languages: map[string]string{
+				"Text": LinterStub,
+				"go":   LinterStaticcheck,
+			},
+			Staticcheck: "staticcheck",
+			Timeout:     30 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogText,
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+			ServiceName: "doccer",
+		},
+		RateLimit: RateLimitConfig{
+			Writes: BucketConfig{PerMinute: 60, Burst: 20},
+			Lint:   BucketConfig{PerMinute: 10, Burst: 5},
+		},
+		Quotas: QuotaConfig{
+			MaxDocs:           1000,
+			MaxBytes:          64 << 20,
+			AnonymousMaxDocs:  1000,
+			AnonymousMaxBytes: 16 << 20,
+		},
+		Limits: LimitsConfig{
+			MaxBodyBytes:        2 << 20,
+			MaxDocBytes:         1 << 20,
+			MaxTitleBytes:       1 << 10,
+			MaxDescriptionBytes: 16 << 10,
+		},
+	}
+}
+
+// DSN is the lib/pq connection string of the database.
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(d.Host), d.Port, quoteDSN(d.User), quoteDSN(d.Password), quoteDSN(d.Name), quoteDSN(d.SSLMode))
+}
+
+func quoteDSN(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// resolveSecrets reads the secrets given as files.
+func (c *Config) resolveSecrets() error {
+	for _, secret := range []struct {
+		value *string
+		file  string
+		name  string
+	}{
+		{&c.Database.Password, c.Database.PasswordFile, "database.password"},
+		{&c.Auth.JwtSecret, c.Auth.JwtSecretFile, "auth.jwt_secret"},
+		{&c.Auth.AdminPassword, c.Auth.AdminPasswordFile, "auth.admin_password"},
+	} {
+		if secret.file == "" {
+			continue
+		}
+		if *secret.value != "" {
+			return fmt.Errorf("%s and %s_file are both set", secret.name, secret.name)
+		}
+		b, err := os.ReadFile(secret.file)
+		if err != nil {
+			return fmt.Errorf("%s_file: %w", secret.name, err)
+		}
+		*secret.value = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Http.Addr != "", "http.addr must be set")
+	check(c.Http.ReadTimeout >= 0, "http.read_timeout must not be negative")
+	check(c.Http.WriteTimeout >= 0, "http.write_timeout must not be negative")
+	check(c.Http.IdleTimeout >= 0, "http.idle_timeout must not be negative")
+	check(c.Http.MaxHeaderBytes >= 0, "http.max_header_bytes must not be negative")
+	check(c.Http.MetricsAddr != c.Http.Addr, "http.metrics_addr must differ from http.addr")
+	check(c.Http.ShutdownTimeout >= 0, "http.shutdown_timeout must not be negative")
+
+	check(c.Database.Host != "", "database.host must be set")
+	check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port must be between 1 and 65535")
+	check(c.Database.User != "", "database.user must be set")
+	check(c.Database.Name != "", "database.name must be set")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.ConnectTimeout >= 0, "database.connect_timeout must not be negative")
+
+	check(c.Auth.JwtSecret != "", "auth.jwt_secret or auth.jwt_secret_file must be set")
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl must be positive")
+	check(len(c.Auth.Admins) == 0 || c.Auth.AdminPassword != "",
+		"auth.admin_password or auth.admin_password_file must be set with auth.admins")
+
+	check(c.Workers.Save > 0, "workers.save must be positive")
+	check(c.Workers.Linter > 0, "workers.linter must be positive")
+	check(c.Workers.DrainTimeout >= 0, "workers.drain_timeout must not be negative")
+	check(c.Workers.SaveTimeout > 0, "workers.save_timeout must be positive")
+
+	for lang, linter := range c.Linter.Languages {
+		check(linter == LinterStaticcheck || linter == LinterStub || linter == LinterNone,
+			"linter.languages.%s must be %s, %s or %s", lang, LinterStaticcheck, LinterStub, LinterNone)
+	}
+	check(c.Linter.Staticcheck != "", "linter.staticcheck must be set")
+	check(c.Linter.Timeout > 0, "linter.timeout must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level must be debug, info, warn or error")
+	check(c.Log.Format == LogText || c.Log.Format == LogJson, "log.format must be %s or %s", LogText, LogJson)
+
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name must be set")
+
+	for _, bucket := range []struct {
+		name string
+		cfg  BucketConfig
+	}{
+		{"writes", c.RateLimit.Writes},
+		{"lint", c.RateLimit.Lint},
+	} {
+		check(bucket.cfg.PerMinute >= 0, "rate_limit.%s.per_minute must not be negative", bucket.name)
+		check(bucket.cfg.PerMinute == 0 || bucket.cfg.Burst > 0, "rate_limit.%s.burst must be positive", bucket.name)
+	}
+	check(c.Quotas.MaxDocs >= 0, "quotas.max_docs must not be negative")
+	check(c.Quotas.MaxBytes >= 0, "quotas.max_bytes must not be negative")
+	check(c.Quotas.AnonymousMaxDocs >= 0, "quotas.anonymous_max_docs must not be negative")
+	check(c.Quotas.AnonymousMaxBytes >= 0, "quotas.anonymous_max_bytes must not be negative")
+	check(c.Limits.MaxBodyBytes >= 0, "limits.max_body_bytes must not be negative")
+	check(c.Limits.MaxDocBytes >= 0, "limits.max_doc_bytes must not be negative")
+	check(c.Limits.MaxTitleBytes >= 0, "limits.max_title_bytes must not be negative")
+	check(c.Limits.MaxDescriptionBytes >= 0, "limits.max_description_bytes must not be negative")
+	check(c.Limits.MaxBodyBytes == 0 || c.Limits.MaxBodyBytes >= c.Limits.MaxDocBytes,
+		"limits.max_body_bytes must not be below limits.max_doc_bytes")
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the config from the defaults, the YAML file, the environment
+// (as returned by os.Environ) and the command line args, in this order,
+// then reads the secret files and validates the result.
+func Load(args []string, environ []string) (*Config, error) {
+	cfg := Default()
+	env := envMap(environ)
+
+	fs := flag.NewFlagSet("doccer-server", flag.ContinueOnError)
+	path := fs.String("config", env["DOCCER_CONFIG"], "YAML config file (default $DOCCER_CONFIG)")
+	flagValues := map[string]*rawValue{}
+	for _, s := range settings(&cfg) {
+		value := &rawValue{}
+		flagValues[s.path] = value
+		fs.Var(value, s.path, fmt.Sprintf("%s (default %v, $%s)", s.path, s.value.Interface(), s.env()))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(&cfg, *path); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range settings(&cfg) {
+		if v, ok := env[s.env()]; ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("$%s: %w", s.env(), err)
+			}
+		}
+		if v := flagValues[s.path]; v.isSet {
+			if err := s.set(v.value); err != nil {
+				return nil, fmt.Errorf("-%s: %w", s.path, err)
+			}
+		}
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func envMap(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// rawValue keeps a flag as given, so flags can be applied after the file
+// and the environment.
+type rawValue struct {
+	value string
+	isSet bool
+}
+
+func (v *rawValue) String() string {
+	return v.value
+}
+
+func (v *rawValue) Set(s string) error {
+	v.value, v.isSet = s, true
+	return nil
+}
+
+// setting is a scalar field of the config.
+type setting struct {
+	path  string
+	value reflect.Value
+}
+
+func (s setting) env() string {
+	return "DOCCER_" + strings.ToUpper(strings.ReplaceAll(s.path, ".", "_"))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s setting) set(raw string) error {
+	v := s.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		// lists are comma-separated outside the file
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("cannot set %s", v.Type())
+	}
+	return nil
+}
+
+// settings lists the scalar fields of cfg by their YAML paths. Maps are
+// only set from the file.
+func settings(cfg *Config) []setting {
+	var res []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			name := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			field := v.Field(i)
+			switch field.Kind() {
+			case reflect.Struct:
+				walk(prefix+name+".", field)
+			case reflect.Map:
+			default:
+				res = append(res, setting{path: prefix + name, value: field})
+			}
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return res
+}
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownAccessLevel = errors.New("unknown access level")
+
+// AccessLevel is an ordered access level: a user with a higher level
+// can do everything allowed by the lower ones.
+type AccessLevel int
+
+const (
+	AccessNone AccessLevel = iota
+	AccessRead
+	AccessEdit
+	AccessAbsolute
+)
+
+var accessLevelNames = []string{"none", "read", "edit", "absolute"}
+
+func ParseAccessLevel(s string) (AccessLevel, error) {
+	for i, name := range accessLevelNames {
+		if name == s {
+			return AccessLevel(i), nil
+		}
+	}
+	return AccessNone, fmt.Errorf("%w: %q", ErrUnknownAccessLevel, s)
+}
+
+func (a AccessLevel) Valid() bool {
+	return a >= AccessNone && a <= AccessAbsolute
+}
+
+func (a AccessLevel) String() string {
+	if !a.Valid() {
+		return fmt.Sprintf("AccessLevel(%d)", int(a))
+	}
+	return accessLevelNames[a]
+}
+
+// AtLeast reports whether a grants everything that other grants.
+func (a AccessLevel) AtLeast(other AccessLevel) bool {
+	return a >= other
+}
+
+func (a AccessLevel) MarshalJSON() ([]byte, error) {
+	if !a.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownAccessLevel, int(a))
+	}
+	return json.Marshal(a.String())
+}
+
+func (a *AccessLevel) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	level, err := ParseAccessLevel(s)
+	if err != nil {
+		return err
+	}
+	*a = level
+	return nil
+}
+
+// Value stores the level as its integer code.
+func (a AccessLevel) Value() (driver.Value, error) {
+	if !a.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownAccessLevel, int(a))
+	}
+	return int64(a), nil
+}
+
+func (a *AccessLevel) Scan(src interface{}) error {
+	var code int64
+	switch v := src.(type) {
+	case int64:
+		code = v
+	case nil:
+		code = int64(AccessNone)
+	default:
+		return fmt.Errorf("cannot scan %T into AccessLevel", src)
+	}
+	level := AccessLevel(code)
+	if !level.Valid() {
+		return fmt.Errorf("%w: %d", ErrUnknownAccessLevel, code)
+	}
+	*a = level
+	return nil
+}
+
+// Sources of an AccessGrant.
+const (
+	GrantAuthor   = "author"
+	GrantPublic   = "public"
+	GrantMember   = "member"
+	GrantGroup    = "group"
+	GrantOrg      = "org"
+	GrantOrgAdmin = "org-admin"
+
+	GrantFolderOwner  = "folder-owner"
+	GrantFolderMember = "folder-member"
+	GrantFolderGroup  = "folder-group"
+)
+
+// AccessGrant is a single rule that gives a user access to a doc.
+type AccessGrant struct {
+	Source string      `json:"source"`
+	ItemId Id          `json:"itemId,omitempty"`
+	Name   string      `json:"name,omitempty"`
+	Access AccessLevel `json:"access"`
+}
+
+// AccessExplanation lists every grant contributing to a user's access
+// and the one that determines the effective level.
+type AccessExplanation struct {
+	DocId     Id            `json:"docId"`
+	UserId    Id            `json:"userId"`
+	Effective AccessLevel   `json:"effective"`
+	Winner    AccessGrant   `json:"winner"`
+	Grants    []AccessGrant `json:"grants"`
+}
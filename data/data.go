@@ -1,24 +1,147 @@
 package data
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
 type Id string
 
+// Scan reads ids stored as integer columns; NULL becomes the empty id.
+func (id *Id) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*id = Id(strconv.FormatInt(v, 10))
+	case string:
+		*id = Id(v)
+	case []byte:
+		*id = Id(v)
+	case nil:
+		*id = ""
+	default:
+		return fmt.Errorf("cannot scan %T into Id", src)
+	}
+	return nil
+}
+
 type User struct {
 	Id    Id     `json:"id"`
 	Login string `json:"login"`
 }
 
+// Usage is what the docs authored by a user take up of their quota.
+type Usage struct {
+	Docs  int `json:"docs"`
+	Bytes int `json:"bytes"`
+}
+
 type Doc struct {
-	Id           Id     `json:"id"`
-	AuthorId     Id     `json:"authorId"`
-	Text         string `json:"text"`
-	Access       string `json:"access"`
-	Lang         string `json:"lang"`
-	LinterStatus string `json:"lstatus"`
+	Id           Id          `json:"id"`
+	AuthorId     Id          `json:"authorId"`
+	Text         string      `json:"text,omitempty"`
+	Access       AccessLevel `json:"access"`
+	Lang         string      `json:"lang"`
+	LinterStatus string      `json:"lstatus"`
+	OrgId        Id          `json:"orgId,omitempty"`
+	FolderId     Id          `json:"folderId,omitempty"`
+	Title        string      `json:"title"`
+	Description  string      `json:"description"`
+	Tags         []string    `json:"tags"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+	LastEditedBy Id          `json:"lastEditedBy,omitempty"`
+	// Version grows with every edit. An edit carrying a version fails with
+	// ErrVersionConflict if the doc has been edited since.
+	Version int `json:"version"`
 }
 
 type Group struct {
 	Id      Id     `json:"id"`
 	Name    string `json:"name"`
 	Creator Id     `json:"creator_id"`
+	OrgId   Id     `json:"orgId,omitempty"`
+}
+
+// Org owns docs and groups on behalf of its members. Every member can access
+// org docs at DefaultAccess; admins and owners have absolute access.
+type Org struct {
+	Id            Id          `json:"id"`
+	Name          string      `json:"name"`
+	DefaultAccess AccessLevel `json:"defaultAccess"`
+}
+
+type Member struct {
+	Id    Id     `json:"id"`
+	Login string `json:"login"`
+	Role  Role   `json:"role"`
+}
+
+// Folder holds docs and subfolders. Its member and group grants are
+// inherited by everything inside unless a doc or subfolder overrides them.
+type Folder struct {
+	Id       Id     `json:"id"`
+	ParentId Id     `json:"parentId,omitempty"`
+	Creator  Id     `json:"creatorId"`
+	OrgId    Id     `json:"orgId,omitempty"`
+	Name     string `json:"name"`
+}
+
+type FolderContents struct {
+	Folder  Folder   `json:"folder"`
+	Folders []Folder `json:"folders"`
+	// FoldersNext is the cursor of the next page of subfolders.
+	FoldersNext string `json:"foldersNext,omitempty"`
+	Docs        []Doc  `json:"docs"`
+	// Next is the cursor of the next page of docs.
+	Next string `json:"next,omitempty"`
+}
+
+// Page is a page of a listing. Next is the cursor of the following page,
+// empty on the last one.
+type Page struct {
+	Items interface{} `json:"items"`
+	Next  string      `json:"next,omitempty"`
+}
+
+// SearchResult is a doc matching a search query. Snippet is HTML: escaped
+// text with the matched fragments wrapped in <mark> tags.
+type SearchResult struct {
+	Doc     Doc     `json:"doc"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// Symbol is a declaration found in a Go doc.
+type Symbol struct {
+	DocId    Id     `json:"docId"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Receiver string `json:"receiver,omitempty"`
+	Line     int    `json:"line"`
 }
 
+type MemberGrant struct {
+	UserId Id          `json:"userId"`
+	Login  string      `json:"login"`
+	Access AccessLevel `json:"access"`
+}
+
+type GroupGrant struct {
+	GroupId Id          `json:"groupId"`
+	Name    string      `json:"name"`
+	Access  AccessLevel `json:"access"`
+}
+
+type FolderAcl struct {
+	FolderId Id            `json:"id"`
+	Members  []MemberGrant `json:"members"`
+	Groups   []GroupGrant  `json:"groups"`
+}
+
+type DocAcl struct {
+	DocId   Id            `json:"id"`
+	Public  AccessLevel   `json:"public"`
+	Members []MemberGrant `json:"members"`
+	Groups  []GroupGrant  `json:"groups"`
+}
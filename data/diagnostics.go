@@ -0,0 +1,19 @@
+package data
+
+import "time"
+
+// Readiness tells whether the server can serve requests. Checks maps every
+// check to "ok" or to the reason it failed.
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// LintJob is a lint job in progress: queued, linting or saving.
+type LintJob struct {
+	DocId     Id        `json:"docId"`
+	Lang      string    `json:"lang"`
+	State     string    `json:"state"`
+	RequestId string    `json:"requestId,omitempty"`
+	QueuedAt  time.Time `json:"queuedAt"`
+}
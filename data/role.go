@@ -0,0 +1,86 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is an ordered membership role: owners can do everything admins can,
+// and admins everything members can.
+type Role int
+
+const (
+	RoleMember Role = iota
+	RoleAdmin
+	RoleOwner
+)
+
+var roleNames = []string{"member", "admin", "owner"}
+
+func ParseRole(s string) (Role, error) {
+	for i, name := range roleNames {
+		if name == s {
+			return Role(i), nil
+		}
+	}
+	return RoleMember, fmt.Errorf("%w: %q", ErrUnknownRole, s)
+}
+
+func (r Role) Valid() bool {
+	return r >= RoleMember && r <= RoleOwner
+}
+
+func (r Role) String() string {
+	if !r.Valid() {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+func (r Role) AtLeast(other Role) bool {
+	return r >= other
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	if !r.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownRole, int(r))
+	}
+	return json.Marshal(r.String())
+}
+
+func (r *Role) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	role, err := ParseRole(s)
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+func (r Role) Value() (driver.Value, error) {
+	if !r.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownRole, int(r))
+	}
+	return int64(r), nil
+}
+
+func (r *Role) Scan(src interface{}) error {
+	code, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Role", src)
+	}
+	role := Role(code)
+	if !role.Valid() {
+		return fmt.Errorf("%w: %d", ErrUnknownRole, code)
+	}
+	*r = role
+	return nil
+}
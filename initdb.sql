@@ -1,11 +1,22 @@
+create extension if not exists pg_trgm;
+
+-- bump together with storage.SchemaVersion on every schema change
+create table SchemaVersion(
+    version int not null
+);
+
+insert into SchemaVersion values (1);
+
 create table GeneralInfo(
     base_id int,
     last_user_id int,
     last_group_id int,
-    last_doc_id int
+    last_doc_id int,
+    last_org_id int,
+    last_folder_id int
 );
 
-insert into GeneralInfo values (0, 0, 0, 0);
+insert into GeneralInfo values (0, 0, 0, 0, 0, 0);
 
 create table Users(
     id int primary key,
@@ -18,6 +29,32 @@ create table Password(
     constraint fr_user_id foreign key(id) references Users(id)
 );
 
+create table Orgs(
+    id int primary key,
+    name text,
+    default_access int not null default 0
+);
+
+create table OrgMember(
+    org_id int,
+    member_id int,
+    role int not null default 0,
+    constraint fr_org_id foreign key(org_id) references Orgs(id) on delete cascade,
+    constraint fr_member_id foreign key(member_id) references Users(id),
+    primary key(org_id, member_id)
+);
+
+create table Folders(
+    id int primary key,
+    parent_id int,
+    creator_id int,
+    org_id int,
+    name text,
+    constraint fr_parent_id foreign key(parent_id) references Folders(id) on delete cascade,
+    constraint fr_creator_id foreign key(creator_id) references Users(id),
+    constraint fr_org_id foreign key(org_id) references Orgs(id) on delete set null
+);
+
 create table Docs(
     id int primary key,
     creator_id int,
@@ -25,14 +62,45 @@ create table Docs(
     public_access_type int,
     lang text,
     lstatus text,
-    constraint fr_creator_id foreign key(creator_id) references Users(id)
+    org_id int,
+    folder_id int,
+    title text not null default '',
+    description text not null default '',
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    last_edited_by int,
+    version int not null default 1,
+    search_vector tsvector generated always as (
+        setweight(to_tsvector('simple', title), 'A') ||
+        setweight(to_tsvector('simple', description), 'B') ||
+        setweight(to_tsvector('simple', coalesce(text, '')), 'C')
+    ) stored,
+    constraint fr_creator_id foreign key(creator_id) references Users(id),
+    constraint fr_org_id foreign key(org_id) references Orgs(id) on delete set null,
+    constraint fr_folder_id foreign key(folder_id) references Folders(id) on delete set null,
+    constraint fr_last_edited_by foreign key(last_edited_by) references Users(id)
+);
+
+create table Tags(
+    id serial primary key,
+    name text unique not null
+);
+
+create table DocTags(
+    doc_id int,
+    tag_id int,
+    constraint fr_doc_id foreign key(doc_id) references Docs(id) on delete cascade,
+    constraint fr_tag_id foreign key(tag_id) references Tags(id),
+    primary key(doc_id, tag_id)
 );
 
 create table Groups1(
     id int primary key,
     creator_id int,
     name text,
-    constraint fr_creator_id foreign key(creator_id) references Users(id)
+    org_id int,
+    constraint fr_creator_id foreign key(creator_id) references Users(id),
+    constraint fr_org_id foreign key(org_id) references Orgs(id) on delete set null
 );
 
 
@@ -57,7 +125,165 @@ create table DocMemberRestriction(
 create table GroupMember(
     group_id int,
     member_id int,
+    role int not null default 0,
     constraint fr_group_id foreign key(group_id) references Groups1(id),
     constraint fr_member_id foreign key(member_id) references Users(id),
     primary key(group_id, member_id)
-);
\ No newline at end of file
+);
+
+create table FolderGroupRestriction(
+    folder_id int,
+    group_id int,
+    type int,
+    constraint fr_folder_id foreign key(folder_id) references Folders(id) on delete cascade,
+    constraint fr_group_id foreign key(group_id) references Groups1(id),
+    primary key(folder_id, group_id)
+);
+
+create table FolderMemberRestriction(
+    folder_id int,
+    member_id int,
+    type int,
+    constraint fr_folder_id foreign key(folder_id) references Folders(id) on delete cascade,
+    constraint fr_member_id foreign key(member_id) references Users(id),
+    primary key(folder_id, member_id)
+);
+
+create table GroupSubgroup(
+    group_id int,
+    subgroup_id int,
+    constraint fr_group_id foreign key(group_id) references Groups1(id) on delete cascade,
+    constraint fr_subgroup_id foreign key(subgroup_id) references Groups1(id) on delete cascade,
+    primary key(group_id, subgroup_id)
+);
+
+create table DocSymbols(
+    id serial primary key,
+    doc_id int,
+    name text,
+    kind text,
+    receiver text,
+    line int,
+    constraint fr_doc_id foreign key(doc_id) references Docs(id) on delete cascade
+);
+
+create index GroupMember_member_id_idx on GroupMember(member_id);
+create index DocMemberRestriction_member_id_idx on DocMemberRestriction(member_id);
+create index DocGroupRestriction_group_id_idx on DocGroupRestriction(group_id);
+create index GroupSubgroup_subgroup_id_idx on GroupSubgroup(subgroup_id);
+create index OrgMember_member_id_idx on OrgMember(member_id);
+create index Docs_org_id_idx on Docs(org_id);
+create index Docs_folder_id_idx on Docs(folder_id);
+create index Docs_creator_id_updated_at_idx on Docs(creator_id, updated_at);
+create index DocTags_tag_id_idx on DocTags(tag_id);
+create index Docs_search_vector_idx on Docs using gin(search_vector);
+create index Docs_text_trgm_idx on Docs using gin(text gin_trgm_ops);
+create index Folders_parent_id_idx on Folders(parent_id);
+create index FolderMemberRestriction_member_id_idx on FolderMemberRestriction(member_id);
+create index FolderGroupRestriction_group_id_idx on FolderGroupRestriction(group_id);
+create index DocSymbols_doc_id_idx on DocSymbols(doc_id);
+create index DocSymbols_name_idx on DocSymbols(lower(name) text_pattern_ops);
+
+-- Every grant that contributes to the access of usr to the folder fld.
+-- Member and group grants are inherited from the nearest ancestor that
+-- has one, so a subfolder grant overrides the grant of its parent.
+create function folder_access_grants(fld int, usr int)
+returns table(source text, item_id int, name text, access int) as $$
+    with recursive user_groups(group_id) as (
+        select gm.group_id from GroupMember gm where gm.member_id = usr
+        union
+        select s.group_id from GroupSubgroup s join user_groups ug on ug.group_id = s.subgroup_id
+    ),
+    -- fld and its ancestors, nearest first
+    chain(folder_id, depth) as (
+        select f.id, 0 from Folders f where f.id = fld
+        union all
+        select f.parent_id, c.depth + 1
+        from Folders f join chain c on c.folder_id = f.id
+        where f.parent_id is not null and c.depth < 64
+    )
+    select 'folder-owner', f.id, f.name, 3
+    from chain c join Folders f on f.id = c.folder_id
+    where f.creator_id = usr
+    union all
+    (select 'folder-member', f.id, f.name, r.type
+     from chain c
+     join FolderMemberRestriction r on r.folder_id = c.folder_id
+     join Folders f on f.id = c.folder_id
+     where r.member_id = usr
+     order by c.depth
+     limit 1)
+    union all
+    (select distinct on (r.group_id) 'folder-group', g.id, g.name, r.type
+     from chain c
+     join FolderGroupRestriction r on r.folder_id = c.folder_id
+     join user_groups ug on ug.group_id = r.group_id
+     join Groups1 g on g.id = r.group_id
+     order by r.group_id, c.depth)
+    union all
+    select 'org', o.id, o.name, o.default_access
+    from Folders f
+    join Orgs o on o.id = f.org_id
+    join OrgMember om on om.org_id = o.id
+    where f.id = fld and om.member_id = usr
+    union all
+    select 'org-admin', o.id, o.name, 3
+    from Folders f
+    join Orgs o on o.id = f.org_id
+    join OrgMember om on om.org_id = o.id
+    where f.id = fld and om.member_id = usr and om.role >= 1
+$$ language sql stable;
+
+-- Every grant that contributes to the access of usr to doc. Effective access
+-- is the maximum over these rows; CheckAccess and the access explanation API
+-- both read from here so they cannot disagree.
+create function doc_access_grants(doc int, usr int)
+returns table(source text, item_id int, name text, access int) as $$
+    -- groups of usr, including every group that contains one of them
+    with recursive user_groups(group_id) as (
+        select gm.group_id from GroupMember gm where gm.member_id = usr
+        union
+        select s.group_id from GroupSubgroup s join user_groups ug on ug.group_id = s.subgroup_id
+    )
+    select 'author', u.id, u.login, 3
+    from Docs d join Users u on u.id = d.creator_id
+    where d.id = doc and d.creator_id = usr
+    union all
+    select 'public', null, null, d.public_access_type
+    from Docs d
+    where d.id = doc
+    union all
+    select 'member', u.id, u.login, m.type
+    from DocMemberRestriction m join Users u on u.id = m.member_id
+    where m.doc_id = doc and m.member_id = usr
+    union all
+    select 'group', g.id, g.name, r.type
+    from DocGroupRestriction r
+    join user_groups ug on ug.group_id = r.group_id
+    join Groups1 g on g.id = r.group_id
+    where r.doc_id = doc
+    union all
+    select 'org', o.id, o.name, o.default_access
+    from Docs d
+    join Orgs o on o.id = d.org_id
+    join OrgMember om on om.org_id = o.id
+    where d.id = doc and om.member_id = usr
+    union all
+    -- org admins and owners manage every org doc
+    select 'org-admin', o.id, o.name, 3
+    from Docs d
+    join Orgs o on o.id = d.org_id
+    join OrgMember om on om.org_id = o.id
+    where d.id = doc and om.member_id = usr and om.role >= 1
+    union all
+    -- grants inherited from the doc's folder, unless the doc overrides them
+    -- with its own grant for the same member or group
+    select fg.source, fg.item_id, fg.name, fg.access
+    from Docs d, folder_access_grants(d.folder_id, usr) fg
+    where d.id = doc
+      and fg.source in ('folder-owner', 'folder-member', 'folder-group')
+      and not (fg.source = 'folder-member' and exists (
+          select 1 from DocMemberRestriction m where m.doc_id = doc and m.member_id = usr))
+      and not (fg.source = 'folder-group' and exists (
+          select 1 from DocGroupRestriction r where r.doc_id = doc and r.group_id = fg.item_id))
+$$ language sql stable;
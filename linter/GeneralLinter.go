@@ -1,32 +1,97 @@
 package linter
 
 import (
+	"context"
+	"doccer/config"
 	"doccer/data"
+	"doccer/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"sort"
+	"time"
 )
 
+var tracer = otel.Tracer("doccer/linter")
+
 type GeneralLinter struct {
 	mapper map[string]Linter
+	log *slog.Logger
 }
 
 func NewGeneralLinter() GeneralLinter {
-	return GeneralLinter {make(map[string]Linter) }
+	return GeneralLinter {make(map[string]Linter), slog.Default() }
 }
 
 func (g *GeneralLinter) RegisterNewLinter(langName string, linter Linter) {
 	g.mapper[langName] = linter
 }
 
-func (g *GeneralLinter) CheckCode(doc data.Doc) data.Doc {
+func (g *GeneralLinter) CheckCode(ctx context.Context, doc data.Doc) data.Doc {
+	ctx, span := tracer.Start(ctx, "linter.CheckCode", trace.WithAttributes(
+		attribute.String("doc.id", string(doc.Id)),
+		attribute.String("doc.lang", doc.Lang),
+	))
+	defer span.End()
+	start := time.Now()
 	linter, ok := g.mapper[doc.Lang]
 	if !ok {
+		metrics.ObserveLinterRun(doc.Lang, metrics.LintUnsupported, time.Since(start))
 		doc.LinterStatus = "No inspection for " + doc.Lang
 		return doc
 	}
-	lintRes, err := linter.inspect(doc.Text)
+	lintRes, err := linter.inspect(ctx, doc.Text)
 	if err != nil {
+		outcome := metrics.LintAborted
+		if ctx.Err() == nil {
+			outcome = metrics.LintFailed
+			g.log.WarnContext(ctx, "inspection failed", "doc_id", doc.Id, "lang", doc.Lang, "error", err)
+		}
+		metrics.ObserveLinterRun(doc.Lang, outcome, time.Since(start))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, outcome)
 		doc.LinterStatus = "No inspection"
 		return doc
 	}
+	metrics.ObserveLinterRun(doc.Lang, metrics.LintOk, time.Since(start))
 	doc.LinterStatus = lintRes.comments
 	return doc
 }
+
+// Availability reports, for every language with a linter, why its linter
+// cannot run, or nil if it can.
+func (g *GeneralLinter) Availability() map[string]error {
+	res := make(map[string]error, len(g.mapper))
+	for lang, linter := range g.mapper {
+		res[lang] = linter.available()
+	}
+	return res
+}
+
+// Languages lists, sorted, the languages that have a linter.
+func (g *GeneralLinter) Languages() []string {
+	res := make([]string, 0, len(g.mapper))
+	for lang := range g.mapper {
+		res = append(res, lang)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// NewGeneralLinterFromConfig registers the linters of the configured
+// languages.
+func NewGeneralLinterFromConfig(cfg config.LinterConfig, log *slog.Logger) GeneralLinter {
+	g := NewGeneralLinter()
+	g.log = log
+	for lang, kind := range cfg.Languages {
+		switch kind {
+		case config.LinterStaticcheck:
+			g.RegisterNewLinter(lang, &GoLinter{Path: cfg.Staticcheck, Timeout: cfg.Timeout})
+		case config.LinterStub:
+			g.RegisterNewLinter(lang, &StubLinter{})
+		}
+	}
+	return g
+}
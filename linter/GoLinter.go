@@ -1,15 +1,35 @@
 package linter
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
-type GoLinter struct {}
+type GoLinter struct {
+	// Path of the staticcheck binary; staticcheck is looked up in PATH if empty.
+	Path    string
+	Timeout time.Duration
+}
 
-func (s * GoLinter) inspect(code string) (*InspectionResult, error) {
+func (s * GoLinter) path() string {
+	if s.Path == "" {
+		return "staticcheck"
+	}
+	return s.Path
+}
+
+func (s * GoLinter) available() error {
+	_, err := exec.LookPath(s.path())
+	return err
+}
+
+func (s * GoLinter) inspect(ctx context.Context, code string) (*InspectionResult, error) {
 	file, err := ioutil.TempFile("", "tmp*.go")
 	if err != nil {
 		return nil, err
@@ -20,15 +40,32 @@ func (s * GoLinter) inspect(code string) (*InspectionResult, error) {
 		return nil, err
 	}
 
-	cmd, err := exec.Command("staticcheck", file.Name()).CombinedOutput()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	cmd, err := exec.CommandContext(ctx, s.path(), file.Name()).CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 
 	res := string(cmd)
 	res = strings.Replace(res, file.Name(), "", -1)
 	res = strings.TrimSpace(res)
+	// staticcheck exits with 1 when it finds problems; anything else, or
+	// not running at all, is a failure
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		if res != "" {
+			return nil, fmt.Errorf("staticcheck: %w: %s", err, res)
+		}
+		return nil, fmt.Errorf("staticcheck: %w", err)
+	}
 	if len(res) == 0 {
 		res = "OK"
 	}
 	return &InspectionResult{
 		comments: res,
 	}, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,47 @@
+package linter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeStaticcheck writes a script that prints output and exits with code.
+func fakeStaticcheck(t *testing.T, output string, code string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	path := filepath.Join(t.TempDir(), "staticcheck")
+	script := "#!/bin/sh\nprintf '%s' '" + output + "'\nexit " + code + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGoLinterInspect(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"clean", fakeStaticcheck(t, "", "0"), "OK", false},
+		{"findings", fakeStaticcheck(t, "1:1: unused (U1000)", "1"), "1:1: unused (U1000)", false},
+		{"crash", fakeStaticcheck(t, "panic", "2"), "", true},
+		{"missing", filepath.Join(t.TempDir(), "missing"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := (&GoLinter{Path: tt.path}).inspect(context.Background(), "package main")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && res.comments != tt.want {
+				t.Errorf("comments = %q, want %q", res.comments, tt.want)
+			}
+		})
+	}
+}
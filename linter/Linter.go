@@ -1,7 +1,11 @@
 package linter
 
+import "context"
+
 type Linter interface {
-	inspect(code string) (*InspectionResult, error)
+	inspect(ctx context.Context, code string) (*InspectionResult, error)
+	// available reports why the linter cannot run, if it cannot.
+	available() error
 }
 
 type InspectionResult struct {
@@ -1,9 +1,15 @@
 package linter
 
+import "context"
+
 type StubLinter struct {}
 
-func (s * StubLinter) inspect(code string) (*InspectionResult, error) {
+func (s * StubLinter) inspect(ctx context.Context, code string) (*InspectionResult, error) {
 	return &InspectionResult{
 		comments: "Text inspected",
 	}, nil
 }
+
+func (s * StubLinter) available() error {
+	return nil
+}
@@ -1,48 +1,162 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"doccer/api"
+	"doccer/config"
 	linter2 "doccer/linter"
+	"doccer/logging"
+	"doccer/metrics"
 	"doccer/model"
 	storage2 "doccer/storage"
-	"fmt"
+	"doccer/tracing"
+	"errors"
+	"flag"
 	_ "github.com/lib/pq"
+	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	dbinfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"db", "5432", "postgres", "qwerty", "postgres")
-	db, err := sql.Open("postgres", dbinfo)
+	cfg, err := config.Load(os.Args[1:], os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		println(err.Error())
+		os.Exit(2)
+	}
+	log, level, err := logging.New(cfg.Log, os.Stderr)
 	if err != nil {
 		panic(err)
 	}
-	storage := storage2.PostgresStorage{
-		Dbc: db,
+	go reloadLogLevel(log, level)
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		panic(err)
 	}
-	//delete all data
-	storage.ClearAllTables()
 
-	linter := linter2.NewGeneralLinter()
+	storage, err := storage2.NewPostgresStorage(cfg.Database, log)
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Dbc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Database.ConnectTimeout)
+	err = storage.WaitForDatabase(ctx)
+	if err == nil {
+		err = storage.CheckSchemaVersion(ctx)
+	}
+	cancel()
+	if err != nil {
+		log.Error("cannot start", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Database.ClearOnStart {
+		//delete all data
+		storage.ClearAllTables(context.Background())
+	}
 
-	linter.RegisterNewLinter("Text", &linter2.StubLinter{})
-	linter.RegisterNewLinter("go", &linter2.GoLinter{})
+	linter := linter2.NewGeneralLinterFromConfig(cfg.Linter, log)
 
-	m := model.NewModelImpl(&storage, []byte("abacaba"), linter, 10, 10)
+	validator := model.NewDocValidator(cfg.Limits, linter.Languages())
+	m := model.NewModelImpl(storage2.Instrument(storage), linter, cfg.Auth, cfg.Workers, cfg.Quotas, validator, log)
+	metrics.RegisterDB(storage.Dbc)
+	metrics.RegisterQueues(m.LintQueue, m.SaveQueue)
+	if err := m.Start(context.Background()); err != nil {
+		panic(err)
+	}
 
-	service := api.NewApi(&m)
+	service := api.NewApi(model.Traced(m), log, cfg.RateLimit, validator)
+	if mismatches, err := service.SpecMismatches(); err != nil {
+		log.Warn("cannot read the OpenAPI spec", "error", err)
+	} else {
+		for _, mismatch := range mismatches {
+			log.Warn("OpenAPI spec mismatch", "mismatch", mismatch)
+		}
+	}
 
 	server := http.Server {
-		Addr:         ":8080",
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		Handler:      service.Router(),
+		Addr:           cfg.Http.Addr,
+		ReadTimeout:    cfg.Http.ReadTimeout,
+		WriteTimeout:   cfg.Http.WriteTimeout,
+		IdleTimeout:    cfg.Http.IdleTimeout,
+		MaxHeaderBytes: cfg.Http.MaxHeaderBytes,
+		Handler:        service.Router(),
 	}
-	println("Starting server")
-	err = server.ListenAndServe()
-	if err != nil {
-		panic(err)
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	serverErr := make(chan error, 2)
+	go func() {
+		log.Info("starting server", "addr", cfg.Http.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+	var metricsServer *http.Server
+	if cfg.Http.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:         cfg.Http.MetricsAddr,
+			ReadTimeout:  cfg.Http.ReadTimeout,
+			WriteTimeout: cfg.Http.WriteTimeout,
+			Handler:      mux,
+		}
+		go func() {
+			log.Info("serving metrics", "addr", cfg.Http.MetricsAddr)
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Error("server failed", "error", err)
+	case <-signals.Done():
+		log.Info("shutting down")
+	}
+	stopSignals()
+
+	// requests first, so that no lint jobs are queued while draining
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.Http.ShutdownTimeout)
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("server shutdown failed", "error", err)
+	}
+	if metricsServer != nil {
+		_ = metricsServer.Close()
+	}
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.Workers.DrainTimeout)
+	if err := m.Stop(ctx); err != nil {
+		log.Error("lint workers stop failed", "error", err)
+	}
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), cfg.Http.ShutdownTimeout)
+	if err := stopTracing(ctx); err != nil {
+		log.Error("flushing spans failed", "error", err)
+	}
+	cancel()
+	log.Info("stopped")
+}
+
+// reloadLogLevel sets the log level from the config again on SIGHUP.
+func reloadLogLevel(log *slog.Logger, level *slog.LevelVar) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		cfg, err := config.Load(os.Args[1:], os.Environ())
+		if err != nil {
+			log.Error("reloading config failed", "error", err)
+			continue
+		}
+		if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+			log.Error("reloading config failed", "error", err)
+			continue
+		}
+		log.Info("log level set", "level", level.Level())
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,143 @@
+// Package metrics holds the Prometheus collectors of the server, which
+// /metrics exposes.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "doccer"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	linterRuns = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "linter_run_duration_seconds",
+		Help:      "Duration of linter runs by doc language and outcome.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"lang", "outcome"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_call_duration_seconds",
+		Help:      "Latency of storage calls by Storage method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"method"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Failed logins and rejected requests by reason.",
+	}, []string{"reason"})
+)
+
+// Linter run outcomes.
+const (
+	LintOk          = "ok"
+	LintFailed      = "failed"
+	LintAborted     = "aborted"
+	LintUnsupported = "unsupported"
+)
+
+// Auth failure reasons.
+const (
+	AuthMissingToken  = "missing_token"
+	AuthInvalidToken  = "invalid_token"
+	AuthExpiredToken  = "expired_token"
+	AuthWrongPassword = "wrong_password"
+	AuthUnknownUser   = "unknown_user"
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		linterRuns,
+		storageDuration,
+		authFailures,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveRequest(method string, route string, status int, latency time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(latency.Seconds())
+}
+
+func ObserveLinterRun(lang string, outcome string, duration time.Duration) {
+	linterRuns.WithLabelValues(lang, outcome).Observe(duration.Seconds())
+}
+
+func ObserveStorageCall(method string, start time.Time) {
+	storageDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func AuthFailed(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// RegisterQueues exposes the lengths and capacities of the lint queue, of
+// docs waiting for a linter, and of the save queue, of lint results
+// waiting to be stored.
+func RegisterQueues(lint func() (int, int), save func() (int, int)) {
+	for _, queue := range []struct {
+		name   string
+		length func() (int, int)
+	}{
+		{"lint", lint},
+		{"save", save},
+	} {
+		length := queue.length
+		registry.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "queue_length",
+				Help:        "Jobs waiting in a worker queue.",
+				ConstLabels: prometheus.Labels{"queue": queue.name},
+			}, func() float64 {
+				n, _ := length()
+				return float64(n)
+			}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "queue_capacity",
+				Help:        "Capacity of a worker queue.",
+				ConstLabels: prometheus.Labels{"queue": queue.name},
+			}, func() float64 {
+				_, n := length()
+				return float64(n)
+			}),
+		)
+	}
+}
+
+// RegisterDB exposes the connection pool stats of db.
+func RegisterDB(db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
@@ -0,0 +1,16 @@
+package model
+
+import "context"
+
+type requestIdKey struct{}
+
+// WithRequestId tags ctx with the id of the request it serves, which
+// follows the request into storage calls and lint jobs.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"context"
+	"doccer/auth"
+	"doccer/data"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CheckReadiness checks the database and its schema, the linters of every
+// language and the workers.
+func (s *ModelImpl) CheckReadiness(ctx context.Context) data.Readiness {
+	checks := map[string]error{
+		"database": s.storage.Ping(ctx),
+		"schema":   s.storage.CheckSchemaVersion(ctx),
+		"workers":  s.checkWorkers(),
+	}
+	for lang, err := range s.linter.Availability() {
+		checks["linter."+lang] = err
+	}
+
+	res := data.Readiness{Ready: true, Checks: map[string]string{}}
+	for name, err := range checks {
+		if err != nil {
+			res.Ready = false
+			res.Checks[name] = err.Error()
+		} else {
+			res.Checks[name] = "ok"
+		}
+	}
+	return res
+}
+
+func (s *ModelImpl) checkWorkers() error {
+	s.mu.RLock()
+	started, stopped := s.started, s.stopped
+	s.mu.RUnlock()
+	switch {
+	case !started:
+		return errors.New("not started")
+	case stopped:
+		return errors.New("stopping")
+	}
+	if n := int(s.linterAlive.Load()); n != s.linterWorkersCnt {
+		return fmt.Errorf("%d of %d linter workers running", n, s.linterWorkersCnt)
+	}
+	if n := int(s.saveAlive.Load()); n != s.saveWorkersCnt {
+		return fmt.Errorf("%d of %d save workers running", n, s.saveWorkersCnt)
+	}
+	return nil
+}
+
+// IsAdmin tells whether the user is one of the admin accounts made by Start.
+func (s *ModelImpl) IsAdmin(ctx context.Context, userId data.Id) (bool, error) {
+	return s.isAdmin(userId), nil
+}
+
+func (s *ModelImpl) isAdmin(userId data.Id) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.adminIds[userId]
+}
+
+// ensureAdmins creates the accounts of the configured admin logins and sets
+// the admin password on those that exist, so that an admin login claimed
+// by someone else before it was configured is taken back. Admins are told
+// apart by the ids of these accounts. The caller holds s.mu.
+func (s *ModelImpl) ensureAdmins(ctx context.Context) error {
+	ids := map[data.Id]bool{}
+	if len(s.admins) > 0 {
+		password, err := auth.EncodeStr(s.adminPassword)
+		if err != nil {
+			return err
+		}
+		for login := range s.admins {
+			user, err := s.storage.GetUserByLogin(ctx, login)
+			switch {
+			case err == ErrNotFound:
+				user = &data.User{Id: s.nextId(ctx, true), Login: login}
+				err = s.storage.AddUser(ctx, *user, password)
+			case err == nil:
+				err = s.storage.SetPassword(ctx, user.Id, password)
+			}
+			if err != nil {
+				return fmt.Errorf("admin %s: %w", login, err)
+			}
+			ids[user.Id] = true
+		}
+	}
+	s.adminIds = ids
+	return nil
+}
+
+// GetLintJobs lists the lint jobs in progress, oldest first.
+func (s *ModelImpl) GetLintJobs(ctx context.Context) []data.LintJob {
+	s.jobsMu.Lock()
+	res := make([]data.LintJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		res = append(res, *job)
+	}
+	s.jobsMu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].QueuedAt.Before(res[j].QueuedAt)
+	})
+	return res
+}
@@ -1,11 +1,56 @@
 package model
 
-import "errors"
+// Error is a domain error. Code is stable and meant for clients, Message
+// for humans. Details names the offending fields of a validation error.
+type Error struct {
+	Code    string
+	Message string
+	Details []FieldError
+}
+
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is match errors with the same code, so the ones carrying
+// details still compare equal to the variables below.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
 
 var (
-	ErrAlreadyExists  = errors.New("already exists")
-	ErrNotImplemented = errors.New("not implemented")
-	ErrNotFound = errors.New("not found")
-	ErrWrongPassword = errors.New("wrong password")
-	ErrNoAccess = errors.New("no access")
+	ErrAlreadyExists  = &Error{Code: "already_exists", Message: "already exists"}
+	ErrNotImplemented = &Error{Code: "not_implemented", Message: "not implemented"}
+	ErrNotFound = &Error{Code: "not_found", Message: "not found"}
+	ErrWrongPassword = &Error{Code: "wrong_password", Message: "wrong login or password"}
+	ErrUnauthorized = &Error{Code: "unauthorized", Message: "missing or invalid authorization token"}
+	ErrNoAccess = &Error{Code: "no_access", Message: "no access"}
+	ErrInvalidRequest = &Error{Code: "invalid_request", Message: "invalid request"}
+	ErrValidation = &Error{Code: "validation_failed", Message: "validation failed"}
+	ErrGroupCycle = &Error{Code: "group_cycle", Message: "group cycle"}
+	ErrFolderCycle = &Error{Code: "folder_cycle", Message: "folder cycle"}
+	ErrVersionConflict = &Error{Code: "version_conflict", Message: "doc was edited since the given version"}
+	ErrUnavailable = &Error{Code: "unavailable", Message: "the server is shutting down"}
+	ErrRateLimited = &Error{Code: "rate_limited", Message: "too many requests, retry later"}
+	ErrQuotaExceeded = &Error{Code: "quota_exceeded", Message: "storage quota exceeded"}
+	ErrTooLarge = &Error{Code: "too_large", Message: "too large"}
 )
+
+// ValidationError reports the fields of a request that are invalid.
+func ValidationError(details ...FieldError) error {
+	return &Error{
+		Code:    ErrValidation.Code,
+		Message: ErrValidation.Message,
+		Details: details,
+	}
+}
+
+func invalidField(field string, message string) error {
+	return ValidationError(FieldError{Field: field, Message: message})
+}
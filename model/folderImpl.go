@@ -0,0 +1,172 @@
+package model
+
+import (
+	"context"
+	"doccer/data"
+)
+
+func (s *ModelImpl) checkFolderAccess(ctx context.Context, userId data.Id, folderId data.Id, required data.AccessLevel) error {
+	acc, err := s.storage.CheckFolderAccess(ctx, userId, folderId)
+	if err != nil {
+		return err
+	}
+	if !acc.AtLeast(required) {
+		return ErrNoAccess
+	}
+	return nil
+}
+
+// CreateFolder creates a top-level folder, or a subfolder if the caller can
+// edit the parent. Subfolders belong to the org of their parent.
+func (s *ModelImpl) CreateFolder(ctx context.Context, userId data.Id, folder data.Folder) (*data.Folder, error) {
+	if folder.ParentId != "" {
+		if err := s.checkFolderAccess(ctx, userId, folder.ParentId, data.AccessEdit); err != nil {
+			return nil, err
+		}
+		parent, err := s.storage.GetFolder(ctx, folder.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		folder.OrgId = parent.OrgId
+	} else if folder.OrgId != "" {
+		if _, err := s.orgRole(ctx, userId, folder.OrgId); err != nil {
+			return nil, err
+		}
+	}
+
+	folder = data.Folder{
+		Id:       s.storage.GenerateNewFolderId(ctx),
+		ParentId: folder.ParentId,
+		Creator:  userId,
+		OrgId:    folder.OrgId,
+		Name:     folder.Name,
+	}
+	return s.storage.CreateFolder(ctx, folder)
+}
+
+// GetFolder lists the first page of subfolders of a folder and a page of its
+// docs.
+func (s *ModelImpl) GetFolder(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) (*data.FolderContents, error) {
+	if err := page.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessRead); err != nil {
+		return nil, err
+	}
+	return s.storage.GetFolderContents(ctx, userId, folderId, page)
+}
+
+func (s *ModelImpl) GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) ([]data.Folder, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessRead); err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetSubfolders(ctx, userId, folderId, page)
+}
+
+func (s *ModelImpl) GetUserFolders(ctx context.Context, userId data.Id, page PageRequest) ([]data.Folder, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetUserFolders(ctx, userId, page)
+}
+
+func (s *ModelImpl) RenameFolder(ctx context.Context, userId data.Id, folderId data.Id, name string) (*data.Folder, error) {
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessEdit); err != nil {
+		return nil, err
+	}
+	if err := s.storage.RenameFolder(ctx, folderId, name); err != nil {
+		return nil, err
+	}
+	return s.storage.GetFolder(ctx, folderId)
+}
+
+// MoveFolder moves a folder under parentId, or to the top level if parentId
+// is empty. Moving changes inherited grants, so it needs absolute access to
+// the folder and edit access to the new parent.
+func (s *ModelImpl) MoveFolder(ctx context.Context, userId data.Id, folderId data.Id, parentId data.Id) (*data.Folder, error) {
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessAbsolute); err != nil {
+		return nil, err
+	}
+	if parentId != "" {
+		if err := s.checkFolderAccess(ctx, userId, parentId, data.AccessEdit); err != nil {
+			return nil, err
+		}
+		if parentId == folderId {
+			return nil, ErrFolderCycle
+		}
+		cycle, err := s.storage.FolderContains(ctx, folderId, parentId)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, ErrFolderCycle
+		}
+	}
+	if err := s.storage.MoveFolder(ctx, folderId, parentId); err != nil {
+		return nil, err
+	}
+	return s.storage.GetFolder(ctx, folderId)
+}
+
+func (s *ModelImpl) DeleteFolder(ctx context.Context, userId data.Id, folderId data.Id) error {
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessAbsolute); err != nil {
+		return err
+	}
+	return s.storage.DeleteFolder(ctx, folderId)
+}
+
+// MoveDoc puts a doc into a folder, or back to the top level if folderId
+// is empty. The doc then inherits the folder's grants.
+func (s *ModelImpl) MoveDoc(ctx context.Context, userId data.Id, docId data.Id, folderId data.Id) (*data.Doc, error) {
+	acc, err := s.storage.CheckAccess(ctx, userId, docId)
+	if err != nil {
+		return nil, err
+	}
+	if acc != data.AccessAbsolute {
+		return nil, ErrNoAccess
+	}
+	if folderId != "" {
+		if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessEdit); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.storage.MoveDoc(ctx, docId, folderId); err != nil {
+		return nil, err
+	}
+	return s.getDoc(ctx, userId, docId, true)
+}
+
+// ChangeFolderAccess upserts a member or group grant on the folder
+// identified by request.DocId.
+func (s *ModelImpl) ChangeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.FolderAcl, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkFolderAccess(ctx, userId, request.DocId, data.AccessAbsolute); err != nil {
+		return nil, err
+	}
+	if err := s.storage.EditFolderAccess(ctx, request.DocId, request); err != nil {
+		return nil, err
+	}
+	return s.storage.GetFolderAcl(ctx, request.DocId)
+}
+
+func (s *ModelImpl) GetFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (*data.FolderAcl, error) {
+	if err := s.checkFolderAccess(ctx, userId, folderId, data.AccessAbsolute); err != nil {
+		return nil, err
+	}
+	return s.storage.GetFolderAcl(ctx, folderId)
+}
+
+func (s *ModelImpl) RevokeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error {
+	if request.Type != MemberAccess && request.Type != GroupAccess {
+		return invalidField("type", "must be 0 (member) or 1 (group)")
+	}
+	if err := s.checkFolderAccess(ctx, userId, request.DocId, data.AccessAbsolute); err != nil {
+		return err
+	}
+	return s.storage.DeleteFolderAccess(ctx, request.DocId, request)
+}
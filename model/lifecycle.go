@@ -0,0 +1,249 @@
+package model
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/tracing"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// Start launches the linter and save workers. Cancelling ctx stops them at
+// once, dropping queued lint jobs; Stop drains the queue first.
+func (s *ModelImpl) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("model already started")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.ensureAdmins(ctx); err != nil {
+		return err
+	}
+	s.started = true
+
+	for i := 0; i < s.saveWorkersCnt; i++ {
+		s.saveWg.Add(1)
+		s.saveAlive.Add(1)
+		go s.saveWorker()
+	}
+	for i := 0; i < s.linterWorkersCnt; i++ {
+		s.linterWg.Add(1)
+		s.linterAlive.Add(1)
+		go s.linterWorker()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.abort()
+		case <-s.quit:
+		}
+	}()
+	return nil
+}
+
+// Stop stops accepting lint jobs, lets the linter workers finish the queued
+// ones and waits for their results to be saved. Jobs still queued when ctx
+// is done are dropped and ctx.Err() is returned.
+func (s *ModelImpl) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started || s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	close(s.stopping)
+	s.mu.Unlock()
+	// senders blocked on a full queue give up, then nobody sends to it
+	s.senders.Wait()
+	close(s.processChannel)
+
+	linted := make(chan struct{})
+	go func() {
+		s.linterWg.Wait()
+		// linter workers are the only senders of results
+		close(s.resChannel)
+		s.saveWg.Wait()
+		// forget the dropped jobs
+		s.jobsMu.Lock()
+		s.jobs = map[uint64]*data.LintJob{}
+		s.jobsMu.Unlock()
+		close(linted)
+	}()
+
+	select {
+	case <-linted:
+		s.abort()
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		s.log.Warn("dropped queued lint jobs on stop", "jobs", len(s.processChannel))
+		<-linted
+		return ctx.Err()
+	}
+}
+
+// LintQueue returns the length and the capacity of the queue of docs
+// waiting for a linter.
+func (s *ModelImpl) LintQueue() (int, int) {
+	return len(s.processChannel), cap(s.processChannel)
+}
+
+// SaveQueue returns the length and the capacity of the queue of lint
+// results waiting to be saved.
+func (s *ModelImpl) SaveQueue() (int, int) {
+	return len(s.resChannel), cap(s.resChannel)
+}
+
+// abort makes the linter workers quit without draining the queue.
+func (s *ModelImpl) abort() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+	})
+}
+
+// lintJob is a doc to lint, or its result, with the context of the request
+// that queued it.
+type lintJob struct {
+	id  uint64
+	ctx context.Context
+	doc data.Doc
+}
+
+// enqueueLint queues a lint job, unless the model is stopping. The job
+// outlives the request, so it keeps the values of ctx but not its
+// cancellation; its spans join the trace of the request.
+func (s *ModelImpl) enqueueLint(ctx context.Context, doc data.Doc) (err error) {
+	ctx, span := tracer.Start(ctx, "model.enqueueLint", trace.WithAttributes(attribute.String("doc.id", string(doc.Id))))
+	defer func() {
+		tracing.End(span, err)
+	}()
+	s.mu.RLock()
+	if s.stopped {
+		s.mu.RUnlock()
+		return ErrUnavailable
+	}
+	s.senders.Add(1)
+	s.mu.RUnlock()
+	defer s.senders.Done()
+
+	job := lintJob{id: s.addJob(ctx, doc), ctx: context.WithoutCancel(ctx), doc: doc}
+	select {
+	case s.processChannel <- job:
+		return nil
+	case <-s.stopping:
+		s.setJobState(job.id, "")
+		return ErrUnavailable
+	case <-s.quit:
+		s.setJobState(job.id, "")
+		return ErrUnavailable
+	case <-ctx.Done():
+		s.setJobState(job.id, "")
+		return ctx.Err()
+	}
+}
+
+func (s *ModelImpl) addJob(ctx context.Context, doc data.Doc) uint64 {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	s.lastJobId++
+	s.jobs[s.lastJobId] = &data.LintJob{
+		DocId:     doc.Id,
+		Lang:      doc.Lang,
+		State:     "queued",
+		RequestId: RequestId(ctx),
+		QueuedAt:  time.Now().UTC(),
+	}
+	return s.lastJobId
+}
+
+// setJobState moves a job on; the empty state forgets it.
+func (s *ModelImpl) setJobState(id uint64, state string) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if state == "" {
+		delete(s.jobs, id)
+	} else if job, ok := s.jobs[id]; ok {
+		job.State = state
+	}
+}
+
+func (s *ModelImpl) linterWorker() {
+	defer s.linterWg.Done()
+	defer s.linterAlive.Add(-1)
+	for {
+		select {
+		case <-s.quit:
+			return
+		case job, ok := <-s.processChannel:
+			if !ok {
+				return
+			}
+			s.setJobState(job.id, "linting")
+			start := time.Now()
+			res := s.lint(job)
+			select {
+			case <-s.quit:
+				// aborted runs have no result worth saving
+				s.log.InfoContext(job.ctx, "lint aborted", "doc_id", job.doc.Id, "lang", job.doc.Lang,
+					"duration", time.Since(start))
+				s.setJobState(job.id, "")
+				return
+			default:
+				s.log.InfoContext(job.ctx, "lint done", "doc_id", job.doc.Id, "lang", job.doc.Lang,
+					"duration", time.Since(start))
+				s.setJobState(job.id, "saving")
+				s.resChannel <- res
+			}
+		}
+	}
+}
+
+// lint runs the linter on a job, cancelling it if the model aborts.
+func (s *ModelImpl) lint(job lintJob) lintJob {
+	ctx, cancel := context.WithCancel(job.ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.quit:
+			cancel()
+		case <-done:
+		}
+	}()
+	return lintJob{id: job.id, ctx: job.ctx, doc: s.linter.CheckCode(ctx, job.doc)}
+}
+
+// saveWorker stores lint results until the linter workers are done. Once
+// the model aborts, the results left are dropped.
+func (s *ModelImpl) saveWorker() {
+	defer s.saveWg.Done()
+	defer s.saveAlive.Add(-1)
+	for job := range s.resChannel {
+		select {
+		case <-s.quit:
+			s.setJobState(job.id, "")
+		default:
+			s.save(job)
+		}
+	}
+}
+
+// save stores a lint result. The job context is never cancelled, so the
+// save has a timeout of its own.
+func (s *ModelImpl) save(job lintJob) {
+	ctx, cancel := context.WithTimeout(job.ctx, s.saveTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "model.saveLintResult", trace.WithAttributes(attribute.String("doc.id", string(job.doc.Id))))
+	err := s.storage.SetLinterStatus(ctx, job.doc.Id, job.doc.Version, job.doc.LinterStatus)
+	if err != nil {
+		s.log.ErrorContext(ctx, "saving lint result failed", "doc_id", job.doc.Id, "error", err)
+	}
+	s.setJobState(job.id, "")
+	tracing.End(span, err)
+}
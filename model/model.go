@@ -1,43 +1,236 @@
 package model
 
-import "doccer/data"
+import (
+	"context"
+	"doccer/data"
+	"regexp"
+	"strings"
+	"time"
+)
 
 type UseCasesInterface interface {
-	Register(request LoginRequest) (*data.User, error)
-	Login(request LoginRequest) (*LoginResponse, error)
-	Auth(tokenStr string) (*string, error)
-	Logout(token Token) error
+	Register(ctx context.Context, request LoginRequest) (*data.User, error)
+	Login(ctx context.Context, request LoginRequest) (*LoginResponse, error)
+	Auth(ctx context.Context, tokenStr string) (*string, error)
+	Logout(ctx context.Context, token Token) error
 
-	CreateDoc(userId data.Id, doc data.Doc) (*data.Doc, error)
-	GetDoc(userId data.Id, docId data.Id) (*data.Doc, error)
-	EditDoc(userId data.Id, newDoc data.Doc) (*data.Doc, error)
-	DeleteDoc(userId data.Id, docId data.Id) error
-	ChangeDocAccess(userId data.Id, request DocAccessRequest) (*data.Doc, error)
-	LaunchLinter(userId data.Id, docId data.Id) error
+	CreateDoc(ctx context.Context, userId data.Id, doc data.Doc) (*data.Doc, error)
+	GetDoc(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, error)
+	EditDoc(ctx context.Context, userId data.Id, edit DocEdit) (*data.Doc, error)
+	DeleteDoc(ctx context.Context, userId data.Id, docId data.Id) error
+	ChangeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.Doc, error)
+	GetDocAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.DocAcl, error)
+	RevokeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error
+	ReplaceDocAccess(ctx context.Context, userId data.Id, acl data.DocAcl) (*data.DocAcl, error)
+	ExplainDocAccess(ctx context.Context, userId data.Id, docId data.Id, targetId data.Id) (*data.AccessExplanation, error)
+	LaunchLinter(ctx context.Context, userId data.Id, docId data.Id) error
 
-	GetAllDocs(userId data.Id) ([]data.Doc, error)
+	GetAllDocs(ctx context.Context, userId data.Id, filter DocFilter, page PageRequest) ([]data.Doc, string, error)
+	Search(ctx context.Context, userId data.Id, request SearchRequest, page PageRequest) ([]data.SearchResult, string, error)
+	FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page PageRequest) ([]data.Symbol, string, error)
+	GetDocOutline(ctx context.Context, userId data.Id, docId data.Id, page PageRequest) ([]data.Symbol, string, error)
 
-	GetUserById(userId data.Id) (*data.User, error)
-	EditUser(userId data.Id, newUser data.User) (*data.User, error)
+	GetUserById(ctx context.Context, userId data.Id) (*data.User, error)
+	EditUser(ctx context.Context, userId data.Id, newUser data.User) (*data.User, error)
 
-	CreateGroup(userId data.Id, group data.Group) (*data.Group, error)
-	DeleteGroup(userId data.Id, groupId data.Id) error
-	EditGroup(userId data.Id, newGroup data.Group) (*data.Group, error)
+	CreateGroup(ctx context.Context, userId data.Id, group data.Group) (*data.Group, error)
+	DeleteGroup(ctx context.Context, userId data.Id, groupId data.Id) error
+	EditGroup(ctx context.Context, userId data.Id, newGroup data.Group) (*data.Group, error)
 
-	AddMember(userId data.Id, groupId data.Id, MemberId data.Id) error
-	RemoveMember(userId data.Id, groupId data.Id, memberId data.Id) error
-	GetMembers(userId data.Id, request GroupMembersChunkRequest) ([]data.User, error)
+	AddMember(ctx context.Context, userId data.Id, groupId data.Id, MemberId data.Id) error
+	RemoveMember(ctx context.Context, userId data.Id, groupId data.Id, memberId data.Id) error
+	GetMembers(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Member, string, error)
+	SetMemberRole(ctx context.Context, userId data.Id, request RoleRequest) error
+	TransferGroupOwnership(ctx context.Context, userId data.Id, groupId data.Id, newOwnerId data.Id) error
+
+	AddSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error
+	RemoveSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error
+	GetSubgroups(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Group, string, error)
+
+	CreateOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error)
+	GetOrg(ctx context.Context, userId data.Id, orgId data.Id) (*data.Org, error)
+	EditOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error)
+	DeleteOrg(ctx context.Context, userId data.Id, orgId data.Id) error
+	GetUserOrgs(ctx context.Context, userId data.Id, page PageRequest) ([]data.Org, string, error)
+	GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Doc, string, error)
+
+	AddOrgMember(ctx context.Context, userId data.Id, request OrgMemberRequest) error
+	SetOrgMemberRole(ctx context.Context, userId data.Id, request OrgMemberRequest) error
+	RemoveOrgMember(ctx context.Context, userId data.Id, orgId data.Id, memberId data.Id) error
+	GetOrgMembers(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Member, string, error)
+
+	CreateFolder(ctx context.Context, userId data.Id, folder data.Folder) (*data.Folder, error)
+	GetFolder(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) (*data.FolderContents, error)
+	GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) ([]data.Folder, string, error)
+	GetUserFolders(ctx context.Context, userId data.Id, page PageRequest) ([]data.Folder, string, error)
+	RenameFolder(ctx context.Context, userId data.Id, folderId data.Id, name string) (*data.Folder, error)
+	MoveFolder(ctx context.Context, userId data.Id, folderId data.Id, parentId data.Id) (*data.Folder, error)
+	DeleteFolder(ctx context.Context, userId data.Id, folderId data.Id) error
+	MoveDoc(ctx context.Context, userId data.Id, docId data.Id, folderId data.Id) (*data.Doc, error)
+
+	ChangeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.FolderAcl, error)
+	GetFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (*data.FolderAcl, error)
+	RevokeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error
+
+	CheckReadiness(ctx context.Context) data.Readiness
+	IsAdmin(ctx context.Context, userId data.Id) (bool, error)
+	GetLintJobs(ctx context.Context) []data.LintJob
 }
 
 type Token string
 
 type Password []byte
 
+const (
+	MemberAccess = 0
+	GroupAccess  = 1
+)
+
 type DocAccessRequest struct {
-	DocId  data.Id `json:"id"`
-	Type   int     `json:"type"`
-	ItemId data.Id `json:"itemId"`
-	Access string  `json:"access"`
+	DocId  data.Id          `json:"id"`
+	Type   int              `json:"type"`
+	ItemId data.Id          `json:"itemId"`
+	Access data.AccessLevel `json:"access"`
+}
+
+func (r DocAccessRequest) Validate() error {
+	if r.Type != MemberAccess && r.Type != GroupAccess {
+		return invalidField("type", "must be 0 (member) or 1 (group)")
+	}
+	if !r.Access.Valid() {
+		return invalidField("access", data.ErrUnknownAccessLevel.Error())
+	}
+	return nil
+}
+
+// DocEdit changes the fields of a doc that are set; the ones left out keep
+// their values. A non-zero Version must be the current version of the doc.
+type DocEdit struct {
+	Id          data.Id           `json:"-"`
+	Text        *string           `json:"text,omitempty"`
+	Access      *data.AccessLevel `json:"access,omitempty"`
+	Lang        *string           `json:"lang,omitempty"`
+	Title       *string           `json:"title,omitempty"`
+	Description *string           `json:"description,omitempty"`
+	Tags        *[]string         `json:"tags,omitempty"`
+	Version     int               `json:"version,omitempty"`
+}
+
+// apply returns doc with the fields of the edit.
+func (e DocEdit) apply(doc data.Doc) data.Doc {
+	if e.Text != nil {
+		doc.Text = *e.Text
+	}
+	if e.Access != nil {
+		doc.Access = *e.Access
+	}
+	if e.Lang != nil {
+		doc.Lang = *e.Lang
+	}
+	if e.Title != nil {
+		doc.Title = *e.Title
+	}
+	if e.Description != nil {
+		doc.Description = *e.Description
+	}
+	if e.Tags != nil {
+		doc.Tags = *e.Tags
+	}
+	doc.Version = e.Version
+	return doc
+}
+
+// DocFilter narrows and orders doc listings. Sort is one of "created_at",
+// "updated_at" or "title"; without it docs are listed in creation order.
+// The time bounds are exclusive and unset when zero.
+type DocFilter struct {
+	Tag           string
+	Lang          string
+	Title         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	EditedBy      data.Id
+	Sort          string
+	Desc          bool
+}
+
+// AnonymousId is the user id of requests without a token, and the author of
+// the docs they create.
+const AnonymousId data.Id = "-1"
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// PageRequest selects a page of a listing. Cursor is the Next cursor of the
+// previous page, empty for the first one. Summary leaves doc texts out.
+type PageRequest struct {
+	Cursor  string
+	Limit   int
+	Summary bool
+}
+
+func (p *PageRequest) Validate() error {
+	if p.Limit < 0 {
+		return invalidField("limit", "must not be negative")
+	}
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Limit > MaxPageLimit {
+		p.Limit = MaxPageLimit
+	}
+	return nil
+}
+
+func (f DocFilter) Validate() error {
+	switch f.Sort {
+	case "", "created_at", "updated_at", "title":
+		return nil
+	}
+	return invalidField("sort", "must be one of created_at, updated_at, title")
+}
+
+const (
+	SearchText      = "text"
+	SearchSubstring = "substring"
+	SearchRegex     = "regex"
+)
+
+// SearchRequest describes a search over the docs the caller can read. Text
+// mode runs a full-text query over titles, descriptions and texts, substring
+// and regex modes match the text literally or as a regular expression.
+type SearchRequest struct {
+	Query string
+	Mode  string
+	Lang  string
+	Owner data.Id
+	Tag   string
+}
+
+func (r *SearchRequest) Validate() error {
+	if strings.TrimSpace(r.Query) == "" {
+		return invalidField("q", "must not be empty")
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = SearchText
+	case SearchText, SearchSubstring:
+	case SearchRegex:
+		if _, err := regexp.Compile(r.Query); err != nil {
+			return invalidField("q", err.Error())
+		}
+	default:
+		return invalidField("mode", "must be one of text, substring, regex")
+	}
+	return nil
+}
+
+type MoveRequest struct {
+	FolderId data.Id `json:"folderId"`
 }
 
 type MemberRequest struct {
@@ -45,10 +238,26 @@ type MemberRequest struct {
 	MemberId data.Id `json:"memberId"`
 }
 
-type GroupMembersChunkRequest struct {
-	Id    data.Id `json:"id"`
-	Begin int     `json:"begin"`
-	Size  int     `json:"end"`
+type RoleRequest struct {
+	GroupId  data.Id   `json:"groupId"`
+	MemberId data.Id   `json:"memberId"`
+	Role     data.Role `json:"role"`
+}
+
+type SubgroupRequest struct {
+	GroupId    data.Id `json:"groupId"`
+	SubgroupId data.Id `json:"subgroupId"`
+}
+
+type OwnerRequest struct {
+	GroupId data.Id `json:"groupId"`
+	OwnerId data.Id `json:"ownerId"`
+}
+
+type OrgMemberRequest struct {
+	OrgId    data.Id   `json:"orgId"`
+	MemberId data.Id   `json:"memberId"`
+	Role     data.Role `json:"role"`
 }
 
 type LoginRequest struct {
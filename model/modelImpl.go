@@ -1,79 +1,110 @@
 package model
 
 import (
+	"context"
 	"doccer/auth"
+	"doccer/config"
 	"doccer/data"
 	"doccer/linter"
+	"doccer/metrics"
+	"doccer/symbols"
+	"errors"
 	"github.com/dgrijalva/jwt-go"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type ModelImpl struct {
 	storage Storage
+	log *slog.Logger
 	jwtHandler auth.JwtHandler
-	processChannel chan data.Doc
-	resChannel chan data.Doc
+	linter linter.GeneralLinter
+	admins map[string]bool
+	adminPassword string
+	// adminIds are the ids of the admins, resolved by Start.
+	adminIds map[data.Id]bool
+	quotas config.QuotaConfig
+	validator *DocValidator
+	saveWorkersCnt int
+	linterWorkersCnt int
+	saveTimeout time.Duration
+	processChannel chan lintJob
+	resChannel chan lintJob
+
+	// mu guards the lifecycle below; see lifecycle.go.
+	mu sync.RWMutex
+	started bool
+	stopped bool
+	// stopping is closed by Stop; senders waits for the enqueueLint calls
+	// that got past the stopped check to leave.
+	stopping chan struct{}
+	senders sync.WaitGroup
+	quit chan struct{}
+	quitOnce sync.Once
+	linterWg sync.WaitGroup
+	saveWg sync.WaitGroup
+	linterAlive atomic.Int32
+	saveAlive atomic.Int32
+
+	// jobsMu guards the lint jobs in progress, kept for diagnostics.
+	jobsMu sync.Mutex
+	jobs map[uint64]*data.LintJob
+	lastJobId uint64
 }
 
+// NewModelImpl creates the model; Start launches its workers.
 func NewModelImpl(
 	storage Storage,
-	secret []byte,
 	linter linter.GeneralLinter,
-    saveWorkersCnt int,
-	linterWorkersCnt int,
-	) ModelImpl {
-
-	processChannel := make(chan data.Doc, linterWorkersCnt * 2)
-	resChannel := make(chan data.Doc, saveWorkersCnt * 2)
-
-	res := ModelImpl{
+	authCfg config.AuthConfig,
+	workersCfg config.WorkersConfig,
+	quotas config.QuotaConfig,
+	validator *DocValidator,
+	log *slog.Logger,
+	) *ModelImpl {
+
+	admins := map[string]bool{}
+	for _, login := range authCfg.Admins {
+		admins[login] = true
+	}
+	return &ModelImpl{
 		storage: storage,
-		jwtHandler: auth.NewJwtHandler(secret, 24 * time.Hour),
-		processChannel: processChannel,
-		resChannel: resChannel,
-	}
-
-	for i := 0; i < saveWorkersCnt; i++ {
-		go func() {
-			for {
-				newDoc, ok := <-resChannel
-				if !ok {
-					break
-				}
-				_, _ = res.editDoc(newDoc.AuthorId, newDoc, false)
-			}
-		}()
-	}
-
-	for i := 0; i < linterWorkersCnt; i++ {
-		go func() {
-			for {
-				docToProcess, ok := <- processChannel
-				if !ok {
-					break
-				}
-				resChannel <- linter.CheckCode(docToProcess)
-			}
-		}()
+		log: log,
+		jwtHandler: auth.NewJwtHandler([]byte(authCfg.JwtSecret), authCfg.TokenTTL),
+		linter: linter,
+		admins: admins,
+		adminPassword: authCfg.AdminPassword,
+		quotas: quotas,
+		validator: validator,
+		saveWorkersCnt: workersCfg.Save,
+		linterWorkersCnt: workersCfg.Linter,
+		saveTimeout: workersCfg.SaveTimeout,
+		processChannel: make(chan lintJob, workersCfg.Linter * 2),
+		resChannel: make(chan lintJob, workersCfg.Save * 2),
+		stopping: make(chan struct{}),
+		quit: make(chan struct{}),
+		jobs: map[uint64]*data.LintJob{},
 	}
-
-	return res
 }
 
-func (s *ModelImpl) nextId(isUserId bool) data.Id {
+func (s *ModelImpl) nextId(ctx context.Context, isUserId bool) data.Id {
 	if isUserId {
-		return s.storage.GenerateNewUserId()
+		return s.storage.GenerateNewUserId(ctx)
 	}
-	return s.storage.GenerateNewDocId()
+	return s.storage.GenerateNewDocId(ctx)
 }
 
-func (s *ModelImpl) Register(request LoginRequest) (*data.User, error) {
+func (s *ModelImpl) Register(ctx context.Context, request LoginRequest) (*data.User, error) {
 	user := data.User{
-		Id:    s.nextId(true),
+		Id:    s.nextId(ctx, true),
 		Login: request.Login,
 	}
 
-	if s.storage.CheckLoginExists(user.Login) {
+	if s.admins[user.Login] || s.storage.CheckLoginExists(ctx, user.Login) {
 		return nil, ErrAlreadyExists
 	}
 
@@ -81,25 +112,30 @@ func (s *ModelImpl) Register(request LoginRequest) (*data.User, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = s.storage.AddUser(user, encryptedPassword)
+	err = s.storage.AddUser(ctx, user, encryptedPassword)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (s *ModelImpl) Login(request LoginRequest) (*LoginResponse, error) {
-	user, err := s.storage.GetUserByLogin(request.Login)
+func (s *ModelImpl) Login(ctx context.Context, request LoginRequest) (*LoginResponse, error) {
+	user, err := s.storage.GetUserByLogin(ctx, request.Login)
+	if err == ErrNotFound {
+		metrics.AuthFailed(metrics.AuthUnknownUser)
+		return nil, ErrWrongPassword
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	hashedPassword, err := s.storage.GetHashedPassword(user.Id)
+	hashedPassword, err := s.storage.GetHashedPassword(ctx, user.Id)
 	if err != nil {
 		return nil, err
 	}
 	err = auth.Compare([]byte(request.Password), *hashedPassword)
 	if err != nil {
+		metrics.AuthFailed(metrics.AuthWrongPassword)
 		return nil, ErrWrongPassword
 	}
 
@@ -117,210 +153,530 @@ func (s *ModelImpl) Login(request LoginRequest) (*LoginResponse, error) {
 	return &resp, nil
 }
 
-func (s *ModelImpl) Auth(tokenStr string) (*string, error) {
+func (s *ModelImpl) Auth(ctx context.Context, tokenStr string) (*string, error) {
 	claims, err := s.jwtHandler.ParseClaims(tokenStr, auth.UserClaims{})
 	if err != nil {
+		var jwtErr *jwt.ValidationError
+		if errors.As(err, &jwtErr) && jwtErr.Errors&jwt.ValidationErrorExpired != 0 {
+			metrics.AuthFailed(metrics.AuthExpiredToken)
+		} else {
+			metrics.AuthFailed(metrics.AuthInvalidToken)
+		}
 		return nil, err
 	}
 	userClaims := (*claims).(*auth.UserClaims)
 	return &userClaims.UserId, nil
 }
 
-func (s *ModelImpl) Logout(token Token) error {
+func (s *ModelImpl) Logout(ctx context.Context, token Token) error {
 	return nil
 }
 
-func (s *ModelImpl) CreateDoc(userId data.Id, doc data.Doc) (*data.Doc, error) {
+func (s *ModelImpl) CreateDoc(ctx context.Context, userId data.Id, doc data.Doc) (*data.Doc, error) {
+	if err := s.validator.ValidateDoc(doc, nil); err != nil {
+		return nil, err
+	}
+	if doc.OrgId != "" {
+		if _, err := s.orgRole(ctx, userId, doc.OrgId); err != nil {
+			return nil, err
+		}
+	}
+	if doc.FolderId != "" {
+		if err := s.checkFolderAccess(ctx, userId, doc.FolderId, data.AccessEdit); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkQuota(ctx, userId, 1, len(doc.Text)); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
 	doc = data.Doc{
-		Id:       s.storage.GenerateNewDocId(),
+		Id:       s.storage.GenerateNewDocId(ctx),
 		AuthorId: userId,
 		Text:     doc.Text,
 		Access:   doc.Access,
 		Lang: doc.Lang,
 		LinterStatus: "No inspection",
-	}
-	docId, err := s.storage.AddDoc(doc)
+		OrgId:    doc.OrgId,
+		FolderId: doc.FolderId,
+		Title:        doc.Title,
+		Description:  doc.Description,
+		Tags:         normalizeTags(doc.Tags),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		LastEditedBy: userId,
+		Version:      1,
+	}
+	docId, err := s.storage.AddDoc(ctx, doc)
 
 	if err != nil {
 		return nil, err
 	}
 	doc.Id = *docId
-	s.processChannel <- doc
+	s.indexSymbols(ctx, doc)
+	_ = s.enqueueLint(ctx, doc)
 	return &doc, err
 }
 
-func (s *ModelImpl) GetDoc(userId data.Id, docId data.Id) (*data.Doc, error) {
-	return s.getDoc(userId, docId, true)
+func (s *ModelImpl) GetDoc(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, error) {
+	return s.getDoc(ctx, userId, docId, true)
 }
 
-func (s *ModelImpl) getDoc(userId data.Id, docId data.Id, shouldCheck bool) (*data.Doc, error) {
-	var realAccess string = ""
-	if shouldCheck {
-		checkAccess, err := s.storage.CheckAccess(userId, docId)
-		if err != nil || checkAccess == "none" {
-			return nil, ErrNoAccess
-		}
-		realAccess = checkAccess
+func (s *ModelImpl) getDoc(ctx context.Context, userId data.Id, docId data.Id, shouldCheck bool) (*data.Doc, error) {
+	if !shouldCheck {
+		return s.storage.GetDoc(ctx, docId)
 	}
 
-
-	res, err := s.storage.GetDoc(docId)
+	res, access, err := s.storage.GetDocWithAccess(ctx, userId, docId)
 	if err != nil {
 		return nil, err
 	}
-
-	res.Access = realAccess
+	if access == data.AccessNone {
+		return nil, ErrNoAccess
+	}
+	res.Access = access
 	return res, nil
 }
 
-func (s *ModelImpl) EditDoc(userId data.Id, newDoc data.Doc) (*data.Doc, error) {
-	return s.editDoc(userId, newDoc, true)
+func (s *ModelImpl) EditDoc(ctx context.Context, userId data.Id, edit DocEdit) (*data.Doc, error) {
+	return s.editDoc(ctx, userId, edit, true)
 }
 
-func (s *ModelImpl) LaunchLinter(userId data.Id, docId data.Id) error {
-	doc, err := s.getDoc(userId, docId, true)
+func (s *ModelImpl) LaunchLinter(ctx context.Context, userId data.Id, docId data.Id) error {
+	doc, access, err := s.storage.GetDocWithAccess(ctx, userId, docId)
 	if err != nil {
 		return err
 	}
-	access := doc.Access
-	if access != "edit" && access != "absolute" {
+	if !access.AtLeast(data.AccessEdit) {
 		return ErrNoAccess
 	}
-	s.processChannel <- *doc
-	return nil
+	return s.enqueueLint(ctx, *doc)
 }
 
-func (s *ModelImpl) editDoc(userId data.Id, newDoc data.Doc, updateLinter bool) (*data.Doc, error) {
-	checkAccess, err := s.storage.CheckAccess(userId, newDoc.Id)
-	if err != nil || checkAccess == "none" || checkAccess == "read" {
-		return nil, ErrNoAccess
-	}
-	oldDoc, err := s.getDoc(userId, newDoc.Id, false)
+func (s *ModelImpl) editDoc(ctx context.Context, userId data.Id, edit DocEdit, updateLinter bool) (*data.Doc, error) {
+	oldDoc, checkAccess, err := s.storage.GetDocWithAccess(ctx, userId, edit.Id)
 	if err != nil {
-		return nil, ErrNotFound
+		return nil, err
+	}
+	if !checkAccess.AtLeast(data.AccessEdit) {
+		return nil, ErrNoAccess
 	}
 
-	if oldDoc.Access != newDoc.Access && checkAccess != "absolute" {
+	newDoc := edit.apply(*oldDoc)
+	newDoc.LinterStatus = "No inspection"
+	if oldDoc.Access != newDoc.Access && checkAccess != data.AccessAbsolute {
 		return nil, ErrNoAccess
 	}
-	res, err := s.storage.EditDoc(newDoc)
+	if err := s.validator.ValidateDoc(newDoc, oldDoc); err != nil {
+		return nil, err
+	}
+	// the text counts against the quota of the author, whoever edits it
+	if err := s.checkQuota(ctx, oldDoc.AuthorId, 0, len(newDoc.Text)-len(oldDoc.Text)); err != nil {
+		return nil, err
+	}
+	newDoc.Tags = normalizeTags(newDoc.Tags)
+	newDoc.UpdatedAt = time.Now().UTC()
+	newDoc.LastEditedBy = userId
+	res, err := s.storage.EditDoc(ctx, newDoc)
 	if err != nil {
 		return nil, err
 	}
+	s.indexSymbols(ctx, *res)
 
 	if updateLinter {
-		s.processChannel <- *res
+		_ = s.enqueueLint(ctx, *res)
 	}
 
 	return res, nil
 }
 
-func (s *ModelImpl) DeleteDoc(userId data.Id, docId data.Id) error {
-	checkAccess, err := s.storage.CheckAccess(userId, docId)
-	if err != nil || checkAccess != "absolute" {
+// indexSymbols indexes the Go symbols of a doc as it was just stored. A
+// failure only leaves the index behind the text, so it is logged.
+func (s *ModelImpl) indexSymbols(ctx context.Context, doc data.Doc) {
+	if err := s.storage.ReplaceDocSymbols(ctx, doc.Id, doc.Version, symbols.Extract(doc)); err != nil {
+		s.log.ErrorContext(ctx, "indexing symbols failed", "doc_id", doc.Id, "error", err)
+	}
+}
+
+func (s *ModelImpl) DeleteDoc(ctx context.Context, userId data.Id, docId data.Id) error {
+	checkAccess, err := s.storage.CheckAccess(ctx, userId, docId)
+	if err != nil {
+		return err
+	}
+	if checkAccess != data.AccessAbsolute {
 		return ErrNoAccess
 	}
-	return s.storage.DeleteDoc(docId)
+	return s.storage.DeleteDoc(ctx, docId)
 }
 
-func (s *ModelImpl) ChangeDocAccess(userId data.Id, request DocAccessRequest) (*data.Doc, error) {
-	acc, err := s.storage.CheckAccess(userId, request.DocId)
-	if err != nil || acc != "absolute" {
+func (s *ModelImpl) ChangeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.Doc, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	acc, err := s.storage.CheckAccess(ctx, userId, request.DocId)
+	if err != nil {
+		return nil, err
+	}
+	if acc != data.AccessAbsolute {
 		return nil, ErrNoAccess
 	}
-	err = s.storage.EditDocAccess(request.DocId, request)
+	err = s.storage.EditDocAccess(ctx, request.DocId, request)
+	if err != nil {
+		return nil, err
+	}
+	return s.getDoc(ctx, userId, request.DocId, false)
+}
+
+func (s *ModelImpl) GetDocAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.DocAcl, error) {
+	acc, err := s.storage.CheckAccess(ctx, userId, docId)
 	if err != nil {
 		return nil, err
 	}
-	return s.getDoc(userId, request.DocId, false)
+	if acc != data.AccessAbsolute {
+		return nil, ErrNoAccess
+	}
+	return s.storage.GetDocAcl(ctx, docId)
 }
 
-func (s *ModelImpl) GetAllDocs(userId data.Id) ([]data.Doc, error) {
-	return s.storage.GetAllDocs(userId)
+func (s *ModelImpl) RevokeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error {
+	if request.Type != MemberAccess && request.Type != GroupAccess {
+		return invalidField("type", "must be 0 (member) or 1 (group)")
+	}
+	acc, err := s.storage.CheckAccess(ctx, userId, request.DocId)
+	if err != nil {
+		return err
+	}
+	if acc != data.AccessAbsolute {
+		return ErrNoAccess
+	}
+	return s.storage.DeleteDocAccess(ctx, request.DocId, request)
 }
 
-func (s *ModelImpl) GetUserById(userId data.Id) (*data.User, error) {
-	return s.storage.GetUser(userId)
+func (s *ModelImpl) ReplaceDocAccess(ctx context.Context, userId data.Id, acl data.DocAcl) (*data.DocAcl, error) {
+	var details []FieldError
+	if !acl.Public.Valid() {
+		details = append(details, FieldError{Field: "public", Message: data.ErrUnknownAccessLevel.Error()})
+	}
+	members := map[data.Id]bool{}
+	for i, grant := range acl.Members {
+		if !grant.Access.Valid() {
+			details = append(details, FieldError{Field: "members[" + strconv.Itoa(i) + "].access", Message: data.ErrUnknownAccessLevel.Error()})
+		}
+		if members[grant.UserId] {
+			details = append(details, FieldError{Field: "members[" + strconv.Itoa(i) + "].userId", Message: "is listed more than once"})
+		}
+		members[grant.UserId] = true
+	}
+	groups := map[data.Id]bool{}
+	for i, grant := range acl.Groups {
+		if !grant.Access.Valid() {
+			details = append(details, FieldError{Field: "groups[" + strconv.Itoa(i) + "].access", Message: data.ErrUnknownAccessLevel.Error()})
+		}
+		if groups[grant.GroupId] {
+			details = append(details, FieldError{Field: "groups[" + strconv.Itoa(i) + "].groupId", Message: "is listed more than once"})
+		}
+		groups[grant.GroupId] = true
+	}
+	if len(details) > 0 {
+		return nil, ValidationError(details...)
+	}
+
+	acc, err := s.storage.CheckAccess(ctx, userId, acl.DocId)
+	if err != nil {
+		return nil, err
+	}
+	if acc != data.AccessAbsolute {
+		return nil, ErrNoAccess
+	}
+	err = s.storage.ReplaceDocAcl(ctx, acl)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.GetDocAcl(ctx, acl.DocId)
 }
 
-func (s *ModelImpl) EditUser(userId data.Id, newUser data.User) (*data.User, error) {
+// ExplainDocAccess is available to users with absolute access to the doc
+// and to the user whose access is being explained.
+func (s *ModelImpl) ExplainDocAccess(ctx context.Context, userId data.Id, docId data.Id, targetId data.Id) (*data.AccessExplanation, error) {
+	if userId != targetId {
+		acc, err := s.storage.CheckAccess(ctx, userId, docId)
+		if err != nil {
+			return nil, err
+		}
+		if acc != data.AccessAbsolute {
+			return nil, ErrNoAccess
+		}
+	}
+	grants, err := s.storage.GetAccessGrants(ctx, targetId, docId)
+	if err != nil {
+		return nil, err
+	}
+	return &data.AccessExplanation{
+		DocId:     docId,
+		UserId:    targetId,
+		Effective: grants[0].Access,
+		Winner:    grants[0],
+		Grants:    grants,
+	}, nil
+}
+
+func (s *ModelImpl) GetAllDocs(ctx context.Context, userId data.Id, filter DocFilter, page PageRequest) ([]data.Doc, string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, "", err
+	}
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetAllDocs(ctx, userId, filter, page)
+}
+
+func (s *ModelImpl) Search(ctx context.Context, userId data.Id, request SearchRequest, page PageRequest) ([]data.SearchResult, string, error) {
+	if err := request.Validate(); err != nil {
+		return nil, "", err
+	}
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.storage.SearchDocs(ctx, userId, request, page)
+}
+
+func (s *ModelImpl) FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page PageRequest) ([]data.Symbol, string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, "", invalidField("q", "must not be empty")
+	}
+	switch kind {
+	case "", symbols.KindImport, symbols.KindFunc, symbols.KindMethod, symbols.KindType:
+	default:
+		return nil, "", invalidField("kind", "must be one of import, func, method, type")
+	}
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.storage.FindSymbols(ctx, userId, query, kind, page)
+}
+
+func (s *ModelImpl) GetDocOutline(ctx context.Context, userId data.Id, docId data.Id, page PageRequest) ([]data.Symbol, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	acc, err := s.storage.CheckAccess(ctx, userId, docId)
+	if err != nil {
+		return nil, "", err
+	}
+	if !acc.AtLeast(data.AccessRead) {
+		return nil, "", ErrNoAccess
+	}
+	return s.storage.GetDocSymbols(ctx, docId, page)
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones.
+func normalizeTags(tags []string) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+	return res
+}
+
+func (s *ModelImpl) GetUserById(ctx context.Context, userId data.Id) (*data.User, error) {
+	return s.storage.GetUser(ctx, userId)
+}
+
+func (s *ModelImpl) EditUser(ctx context.Context, userId data.Id, newUser data.User) (*data.User, error) {
 	if userId != newUser.Id {
 		return nil, ErrNoAccess
 	}
-	return s.storage.EditUser(newUser)
+	if s.admins[newUser.Login] && !s.isAdmin(userId) {
+		return nil, ErrAlreadyExists
+	}
+	return s.storage.EditUser(ctx, newUser)
 }
 
-func (s *ModelImpl) CreateGroup(userId data.Id, group data.Group) (*data.Group, error) {
+func (s *ModelImpl) CreateGroup(ctx context.Context, userId data.Id, group data.Group) (*data.Group, error) {
+	if group.OrgId != "" {
+		if _, err := s.orgRole(ctx, userId, group.OrgId); err != nil {
+			return nil, err
+		}
+	}
 	group = data.Group{
-		Id:      s.storage.GenerateNewGroupId(),
+		Id:      s.storage.GenerateNewGroupId(ctx),
 		Name:    group.Name,
 		Creator: userId,
+		OrgId:   group.OrgId,
 	}
 
-	return s.storage.CreateGroup(group)
+	return s.storage.CreateGroup(ctx, group)
 }
 
-func (s *ModelImpl) DeleteGroup(userId data.Id, groupId data.Id) error {
-	g, err := s.storage.GetGroupById(groupId)
+func (s *ModelImpl) DeleteGroup(ctx context.Context, userId data.Id, groupId data.Id) error {
+	role, err := s.groupRole(ctx, userId, groupId)
 	if err != nil {
 		return err
 	}
-	if g.Creator != userId {
+	if role != data.RoleOwner {
 		return ErrNoAccess
 	}
-	return s.storage.DeleteGroup(groupId)
+	return s.storage.DeleteGroup(ctx, groupId)
 }
 
-func (s *ModelImpl) EditGroup(userId data.Id, newGroup data.Group) (*data.Group, error) {
-	oldGroup, err := s.storage.GetGroupById(newGroup.Id)
-	if err != nil || userId != oldGroup.Creator  {
+func (s *ModelImpl) EditGroup(ctx context.Context, userId data.Id, newGroup data.Group) (*data.Group, error) {
+	role, err := s.groupRole(ctx, userId, newGroup.Id)
+	if err != nil || role != data.RoleOwner {
 		return nil, ErrNoAccess
 	}
-	return s.storage.EditGroup(newGroup)
+	return s.storage.EditGroup(ctx, newGroup)
+}
+
+// groupRole returns the role of userId in the group, or ErrNoAccess
+// if the user is neither its creator nor a member. Admins of the org
+// owning the group act as its owners.
+func (s *ModelImpl) groupRole(ctx context.Context, userId data.Id, groupId data.Id) (data.Role, error) {
+	group, err := s.storage.GetGroupById(ctx, groupId)
+	if err != nil {
+		return data.RoleMember, err
+	}
+	if group.OrgId != "" {
+		orgRole, err := s.storage.GetOrgRole(ctx, group.OrgId, userId)
+		if err == nil && orgRole.AtLeast(data.RoleAdmin) {
+			return data.RoleOwner, nil
+		}
+	}
+	role, err := s.storage.GetMemberRole(ctx, groupId, userId)
+	if err != nil {
+		return data.RoleMember, ErrNoAccess
+	}
+	return role, nil
 }
 
-func (s *ModelImpl) AddMember(userId data.Id, groupId data.Id, newMemberId data.Id) error {
-	group, err := s.storage.GetGroupById(groupId)
+func (s *ModelImpl) AddMember(ctx context.Context, userId data.Id, groupId data.Id, newMemberId data.Id) error {
+	role, err := s.groupRole(ctx, userId, groupId)
 	if err != nil {
 		return err
 	}
-	if group.Creator != userId {
+	if !role.AtLeast(data.RoleAdmin) {
 		return ErrNoAccess
 	}
 
-	_, err = s.storage.GetUser(newMemberId)
+	_, err = s.storage.GetUser(ctx, newMemberId)
 	if err != nil {
 		return ErrNotFound
 	}
-	return s.storage.AddMember(groupId, newMemberId)
+	return s.storage.AddMember(ctx, groupId, newMemberId)
 }
 
-func (s *ModelImpl) RemoveMember(userId data.Id, groupId data.Id, memberId data.Id) error {
-	group, err := s.storage.GetGroupById(groupId)
+func (s *ModelImpl) RemoveMember(ctx context.Context, userId data.Id, groupId data.Id, memberId data.Id) error {
+	role, err := s.groupRole(ctx, userId, groupId)
 	if err != nil {
 		return err
 	}
-	if group.Creator != userId {
+	if !role.AtLeast(data.RoleAdmin) {
 		return ErrNoAccess
 	}
 
-	_, err = s.storage.GetUser(memberId)
+	memberRole, err := s.storage.GetMemberRole(ctx, groupId, memberId)
 	if err != nil {
 		return ErrNotFound
 	}
-	return s.storage.RemoveMember(groupId, memberId)
+	if memberRole == data.RoleOwner || (memberRole == data.RoleAdmin && role != data.RoleOwner && memberId != userId) {
+		return ErrNoAccess
+	}
+	return s.storage.RemoveMember(ctx, groupId, memberId)
 }
 
-func (s *ModelImpl) GetMembers(userId data.Id, request GroupMembersChunkRequest) ([]data.User, error) {
-	g, err := s.storage.GetGroupById(request.Id)
+func (s *ModelImpl) GetMembers(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Member, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	role, err := s.groupRole(ctx, userId, groupId)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	if g.Creator != userId {
-		return nil, ErrNoAccess
+	if !role.AtLeast(data.RoleAdmin) {
+		return nil, "", ErrNoAccess
+	}
+	return s.storage.GetMembers(ctx, groupId, page)
+}
+
+func (s *ModelImpl) SetMemberRole(ctx context.Context, userId data.Id, request RoleRequest) error {
+	if request.Role != data.RoleMember && request.Role != data.RoleAdmin {
+		return invalidField("role", "must be member or admin")
+	}
+	role, err := s.groupRole(ctx, userId, request.GroupId)
+	if err != nil {
+		return err
+	}
+	if role != data.RoleOwner {
+		return ErrNoAccess
+	}
+	return s.storage.SetMemberRole(ctx, request.GroupId, request.MemberId, request.Role)
+}
+
+func (s *ModelImpl) TransferGroupOwnership(ctx context.Context, userId data.Id, groupId data.Id, newOwnerId data.Id) error {
+	role, err := s.groupRole(ctx, userId, groupId)
+	if err != nil {
+		return err
+	}
+	if role != data.RoleOwner {
+		return ErrNoAccess
+	}
+	// org admins act as owners without being the creator
+	group, err := s.storage.GetGroupById(ctx, groupId)
+	if err != nil {
+		return err
+	}
+	if newOwnerId == group.Creator {
+		return nil
 	}
-	return s.storage.GetMembers(request)
+	return s.storage.TransferGroupOwnership(ctx, groupId, newOwnerId)
 }
 
+// AddSubgroup nests subgroupId into groupId, so that members of the subgroup
+// get every grant of the group. The caller must administer both groups.
+func (s *ModelImpl) AddSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error {
+	for _, id := range []data.Id{groupId, subgroupId} {
+		role, err := s.groupRole(ctx, userId, id)
+		if err != nil {
+			return err
+		}
+		if !role.AtLeast(data.RoleAdmin) {
+			return ErrNoAccess
+		}
+	}
+
+	if groupId == subgroupId {
+		return ErrGroupCycle
+	}
+	return s.storage.AddSubgroup(ctx, groupId, subgroupId)
+}
 
+func (s *ModelImpl) RemoveSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error {
+	role, err := s.groupRole(ctx, userId, groupId)
+	if err != nil {
+		return err
+	}
+	if !role.AtLeast(data.RoleAdmin) {
+		return ErrNoAccess
+	}
+	return s.storage.RemoveSubgroup(ctx, groupId, subgroupId)
+}
 
+func (s *ModelImpl) GetSubgroups(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Group, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	role, err := s.groupRole(ctx, userId, groupId)
+	if err != nil {
+		return nil, "", err
+	}
+	if !role.AtLeast(data.RoleAdmin) {
+		return nil, "", ErrNoAccess
+	}
+	return s.storage.GetSubgroups(ctx, groupId, page)
+}
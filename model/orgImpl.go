@@ -0,0 +1,153 @@
+package model
+
+import (
+	"context"
+	"doccer/data"
+)
+
+// orgRole returns the role of userId in the org, or ErrNoAccess
+// if the user is not a member.
+func (s *ModelImpl) orgRole(ctx context.Context, userId data.Id, orgId data.Id) (data.Role, error) {
+	_, err := s.storage.GetOrg(ctx, orgId)
+	if err != nil {
+		return data.RoleMember, err
+	}
+	role, err := s.storage.GetOrgRole(ctx, orgId, userId)
+	if err != nil {
+		return data.RoleMember, ErrNoAccess
+	}
+	return role, nil
+}
+
+func (s *ModelImpl) CreateOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error) {
+	if !org.DefaultAccess.Valid() {
+		return nil, invalidField("defaultAccess", data.ErrUnknownAccessLevel.Error())
+	}
+	org = data.Org{
+		Id:            s.storage.GenerateNewOrgId(ctx),
+		Name:          org.Name,
+		DefaultAccess: org.DefaultAccess,
+	}
+	return s.storage.CreateOrg(ctx, org, userId)
+}
+
+func (s *ModelImpl) GetOrg(ctx context.Context, userId data.Id, orgId data.Id) (*data.Org, error) {
+	_, err := s.orgRole(ctx, userId, orgId)
+	if err != nil {
+		return nil, err
+	}
+	return s.storage.GetOrg(ctx, orgId)
+}
+
+func (s *ModelImpl) EditOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error) {
+	if !org.DefaultAccess.Valid() {
+		return nil, invalidField("defaultAccess", data.ErrUnknownAccessLevel.Error())
+	}
+	role, err := s.orgRole(ctx, userId, org.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !role.AtLeast(data.RoleAdmin) {
+		return nil, ErrNoAccess
+	}
+	return s.storage.EditOrg(ctx, org)
+}
+
+func (s *ModelImpl) DeleteOrg(ctx context.Context, userId data.Id, orgId data.Id) error {
+	role, err := s.orgRole(ctx, userId, orgId)
+	if err != nil {
+		return err
+	}
+	if role != data.RoleOwner {
+		return ErrNoAccess
+	}
+	return s.storage.DeleteOrg(ctx, orgId)
+}
+
+func (s *ModelImpl) GetUserOrgs(ctx context.Context, userId data.Id, page PageRequest) ([]data.Org, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetUserOrgs(ctx, userId, page)
+}
+
+func (s *ModelImpl) GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Doc, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	_, err := s.orgRole(ctx, userId, orgId)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetOrgDocs(ctx, userId, orgId, page)
+}
+
+// AddOrgMember lets admins add members; only owners may hand out
+// the admin and owner roles.
+func (s *ModelImpl) AddOrgMember(ctx context.Context, userId data.Id, request OrgMemberRequest) error {
+	if !request.Role.Valid() {
+		return invalidField("role", data.ErrUnknownRole.Error())
+	}
+	role, err := s.orgRole(ctx, userId, request.OrgId)
+	if err != nil {
+		return err
+	}
+	if !role.AtLeast(data.RoleAdmin) || (request.Role != data.RoleMember && role != data.RoleOwner) {
+		return ErrNoAccess
+	}
+
+	_, err = s.storage.GetUser(ctx, request.MemberId)
+	if err != nil {
+		return ErrNotFound
+	}
+	return s.storage.AddOrgMember(ctx, request.OrgId, request.MemberId, request.Role)
+}
+
+func (s *ModelImpl) SetOrgMemberRole(ctx context.Context, userId data.Id, request OrgMemberRequest) error {
+	if !request.Role.Valid() {
+		return invalidField("role", data.ErrUnknownRole.Error())
+	}
+	role, err := s.orgRole(ctx, userId, request.OrgId)
+	if err != nil {
+		return err
+	}
+	if role != data.RoleOwner || request.MemberId == userId {
+		return ErrNoAccess
+	}
+	return s.storage.SetOrgMemberRole(ctx, request.OrgId, request.MemberId, request.Role)
+}
+
+// RemoveOrgMember lets admins remove members and any member leave the org.
+// Owners can only be demoted first, so an org is never left without one to
+// take over the org docs and folders the member created.
+func (s *ModelImpl) RemoveOrgMember(ctx context.Context, userId data.Id, orgId data.Id, memberId data.Id) error {
+	role, err := s.orgRole(ctx, userId, orgId)
+	if err != nil {
+		return err
+	}
+	memberRole, err := s.storage.GetOrgRole(ctx, orgId, memberId)
+	if err != nil {
+		return ErrNotFound
+	}
+	if memberRole == data.RoleOwner {
+		return ErrNoAccess
+	}
+	if memberId != userId && !role.AtLeast(data.RoleAdmin) {
+		return ErrNoAccess
+	}
+	if memberRole == data.RoleAdmin && memberId != userId && role != data.RoleOwner {
+		return ErrNoAccess
+	}
+	return s.storage.RemoveOrgMember(ctx, orgId, memberId)
+}
+
+func (s *ModelImpl) GetOrgMembers(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Member, string, error) {
+	if err := page.Validate(); err != nil {
+		return nil, "", err
+	}
+	_, err := s.orgRole(ctx, userId, orgId)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.storage.GetOrgMembers(ctx, orgId, page)
+}
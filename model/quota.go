@@ -0,0 +1,44 @@
+package model
+
+import (
+	"context"
+	"doccer/data"
+	"fmt"
+)
+
+// checkQuota fails if the docs of the author would exceed the quota after
+// adding docs docs and bytes bytes of text. Only growth is checked, so users
+// over their quota can still clean up. Anonymous docs share one quota.
+func (s *ModelImpl) checkQuota(ctx context.Context, authorId data.Id, docs int, bytes int) error {
+	maxDocs, maxBytes := s.quotas.MaxDocs, s.quotas.MaxBytes
+	if authorId == AnonymousId {
+		maxDocs, maxBytes = s.quotas.AnonymousMaxDocs, s.quotas.AnonymousMaxBytes
+	}
+	checkDocs := docs > 0 && maxDocs > 0
+	checkBytes := bytes > 0 && maxBytes > 0
+	if !checkDocs && !checkBytes {
+		return nil
+	}
+	usage, err := s.storage.GetUserUsage(ctx, authorId)
+	if err != nil {
+		return err
+	}
+
+	var details []FieldError
+	if checkDocs && usage.Docs+docs > maxDocs {
+		details = append(details, FieldError{
+			Field:   "docs",
+			Message: fmt.Sprintf("at most %d docs are allowed, %d are used", maxDocs, usage.Docs),
+		})
+	}
+	if checkBytes && usage.Bytes+bytes > maxBytes {
+		details = append(details, FieldError{
+			Field:   "text",
+			Message: fmt.Sprintf("at most %d bytes of text are allowed, %d are used", maxBytes, usage.Bytes),
+		})
+	}
+	if len(details) > 0 {
+		return &Error{Code: ErrQuotaExceeded.Code, Message: ErrQuotaExceeded.Message, Details: details}
+	}
+	return nil
+}
@@ -1,33 +1,90 @@
 package model
 
-import "doccer/data"
+import (
+	"context"
+	"doccer/data"
+)
 
 type Storage interface {
-	GetUser(userId data.Id) (*data.User, error)
-	GetUserByLogin(login string) (*data.User, error)
-	GetHashedPassword(userId data.Id) (*Password, error)
-    AddUser(newUser data.User, password Password) error
-	EditUser(newUser data.User) (*data.User, error)
-	CheckLoginExists(login string) bool
-
-	CheckAccess(userId data.Id, docId data.Id) (string, error)
-	GetDoc(docId data.Id) (*data.Doc, error)
-	AddDoc(newDoc data.Doc) (*data.Id, error)
-	EditDoc(newDoc data.Doc) (*data.Doc, error)
-	EditDocAccess(docId data.Id, request DocAccessRequest) error
-	DeleteDoc(docId data.Id) error
-	GetAllDocs(userId data.Id) ([]data.Doc, error)
-
-	CreateGroup(group data.Group) (*data.Group, error)
-	DeleteGroup(groupId data.Id) error
-	EditGroup(newGroup data.Group) (*data.Group, error)
-	GetGroupById(groupId data.Id) (*data.Group, error)
-
-	AddMember(groupId data.Id, newMemberId data.Id) error
-	RemoveMember(groupId data.Id, memberId data.Id) error
-	GetMembers(request GroupMembersChunkRequest) ([]data.User, error)
-
-	GenerateNewUserId() data.Id
-	GenerateNewDocId() data.Id
-	GenerateNewGroupId() data.Id
+	Ping(ctx context.Context) error
+	CheckSchemaVersion(ctx context.Context) error
+
+	GetUser(ctx context.Context, userId data.Id) (*data.User, error)
+	GetUserByLogin(ctx context.Context, login string) (*data.User, error)
+	GetHashedPassword(ctx context.Context, userId data.Id) (*Password, error)
+    AddUser(ctx context.Context, newUser data.User, password Password) error
+	EditUser(ctx context.Context, newUser data.User) (*data.User, error)
+	SetPassword(ctx context.Context, userId data.Id, password Password) error
+	CheckLoginExists(ctx context.Context, login string) bool
+
+	CheckAccess(ctx context.Context, userId data.Id, docId data.Id) (data.AccessLevel, error)
+	GetAccessGrants(ctx context.Context, userId data.Id, docId data.Id) ([]data.AccessGrant, error)
+	GetDoc(ctx context.Context, docId data.Id) (*data.Doc, error)
+	GetDocWithAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, data.AccessLevel, error)
+	AddDoc(ctx context.Context, newDoc data.Doc) (*data.Id, error)
+	EditDoc(ctx context.Context, newDoc data.Doc) (*data.Doc, error)
+	EditDocAccess(ctx context.Context, docId data.Id, request DocAccessRequest) error
+	DeleteDocAccess(ctx context.Context, docId data.Id, request DocAccessRequest) error
+	GetDocAcl(ctx context.Context, docId data.Id) (*data.DocAcl, error)
+	ReplaceDocAcl(ctx context.Context, acl data.DocAcl) error
+	DeleteDoc(ctx context.Context, docId data.Id) error
+	SetLinterStatus(ctx context.Context, docId data.Id, version int, status string) error
+	GetUserUsage(ctx context.Context, userId data.Id) (*data.Usage, error)
+	GetAllDocs(ctx context.Context, userId data.Id, filter DocFilter, page PageRequest) ([]data.Doc, string, error)
+	SearchDocs(ctx context.Context, userId data.Id, request SearchRequest, page PageRequest) ([]data.SearchResult, string, error)
+
+	ReplaceDocSymbols(ctx context.Context, docId data.Id, version int, symbols []data.Symbol) error
+	GetDocSymbols(ctx context.Context, docId data.Id, page PageRequest) ([]data.Symbol, string, error)
+	FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page PageRequest) ([]data.Symbol, string, error)
+
+	CreateGroup(ctx context.Context, group data.Group) (*data.Group, error)
+	DeleteGroup(ctx context.Context, groupId data.Id) error
+	EditGroup(ctx context.Context, newGroup data.Group) (*data.Group, error)
+	GetGroupById(ctx context.Context, groupId data.Id) (*data.Group, error)
+
+	AddMember(ctx context.Context, groupId data.Id, newMemberId data.Id) error
+	RemoveMember(ctx context.Context, groupId data.Id, memberId data.Id) error
+	GetMembers(ctx context.Context, groupId data.Id, page PageRequest) ([]data.Member, string, error)
+	GetMemberRole(ctx context.Context, groupId data.Id, userId data.Id) (data.Role, error)
+	SetMemberRole(ctx context.Context, groupId data.Id, memberId data.Id, role data.Role) error
+	TransferGroupOwnership(ctx context.Context, groupId data.Id, newOwnerId data.Id) error
+
+	AddSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error
+	RemoveSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error
+	GetSubgroups(ctx context.Context, groupId data.Id, page PageRequest) ([]data.Group, string, error)
+
+	GenerateNewUserId(ctx context.Context) data.Id
+	GenerateNewDocId(ctx context.Context) data.Id
+	GenerateNewGroupId(ctx context.Context) data.Id
+	GenerateNewOrgId(ctx context.Context) data.Id
+
+	CreateOrg(ctx context.Context, org data.Org, ownerId data.Id) (*data.Org, error)
+	GetOrg(ctx context.Context, orgId data.Id) (*data.Org, error)
+	EditOrg(ctx context.Context, org data.Org) (*data.Org, error)
+	DeleteOrg(ctx context.Context, orgId data.Id) error
+	GetUserOrgs(ctx context.Context, userId data.Id, page PageRequest) ([]data.Org, string, error)
+	GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Doc, string, error)
+
+	GetOrgRole(ctx context.Context, orgId data.Id, userId data.Id) (data.Role, error)
+	AddOrgMember(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error
+	SetOrgMemberRole(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error
+	RemoveOrgMember(ctx context.Context, orgId data.Id, memberId data.Id) error
+	GetOrgMembers(ctx context.Context, orgId data.Id, page PageRequest) ([]data.Member, string, error)
+
+	GenerateNewFolderId(ctx context.Context) data.Id
+	CheckFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (data.AccessLevel, error)
+	CreateFolder(ctx context.Context, folder data.Folder) (*data.Folder, error)
+	GetFolder(ctx context.Context, folderId data.Id) (*data.Folder, error)
+	RenameFolder(ctx context.Context, folderId data.Id, name string) error
+	MoveFolder(ctx context.Context, folderId data.Id, parentId data.Id) error
+	DeleteFolder(ctx context.Context, folderId data.Id) error
+	FolderContains(ctx context.Context, folderId data.Id, descendantId data.Id) (bool, error)
+	GetUserFolders(ctx context.Context, userId data.Id, page PageRequest) ([]data.Folder, string, error)
+	GetFolderContents(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) (*data.FolderContents, error)
+	GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) ([]data.Folder, string, error)
+	MoveDoc(ctx context.Context, docId data.Id, folderId data.Id) error
+
+	EditFolderAccess(ctx context.Context, folderId data.Id, request DocAccessRequest) error
+	DeleteFolderAccess(ctx context.Context, folderId data.Id, request DocAccessRequest) error
+	GetFolderAcl(ctx context.Context, folderId data.Id) (*data.FolderAcl, error)
 }
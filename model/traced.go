@@ -0,0 +1,412 @@
+package model
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/tracing"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("doccer/model")
+
+// tracedUseCases wraps every use case in a span.
+type tracedUseCases struct {
+	next UseCasesInterface
+}
+
+// Traced wraps u to trace its use cases.
+func Traced(u UseCasesInterface) UseCasesInterface {
+	return tracedUseCases{next: u}
+}
+
+func startSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, "model."+name)
+	return ctx, func(err error) {
+		tracing.End(span, err)
+	}
+}
+
+func (u tracedUseCases) Register(ctx context.Context, request LoginRequest) (*data.User, error) {
+	ctx, end := startSpan(ctx, "Register")
+	res, err := u.next.Register(ctx, request)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) Login(ctx context.Context, request LoginRequest) (*LoginResponse, error) {
+	ctx, end := startSpan(ctx, "Login")
+	res, err := u.next.Login(ctx, request)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) Auth(ctx context.Context, tokenStr string) (*string, error) {
+	ctx, end := startSpan(ctx, "Auth")
+	res, err := u.next.Auth(ctx, tokenStr)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) Logout(ctx context.Context, token Token) error {
+	ctx, end := startSpan(ctx, "Logout")
+	err := u.next.Logout(ctx, token)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) CreateDoc(ctx context.Context, userId data.Id, doc data.Doc) (*data.Doc, error) {
+	ctx, end := startSpan(ctx, "CreateDoc")
+	res, err := u.next.CreateDoc(ctx, userId, doc)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetDoc(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, error) {
+	ctx, end := startSpan(ctx, "GetDoc")
+	res, err := u.next.GetDoc(ctx, userId, docId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) EditDoc(ctx context.Context, userId data.Id, edit DocEdit) (*data.Doc, error) {
+	ctx, end := startSpan(ctx, "EditDoc")
+	res, err := u.next.EditDoc(ctx, userId, edit)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) DeleteDoc(ctx context.Context, userId data.Id, docId data.Id) error {
+	ctx, end := startSpan(ctx, "DeleteDoc")
+	err := u.next.DeleteDoc(ctx, userId, docId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) ChangeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.Doc, error) {
+	ctx, end := startSpan(ctx, "ChangeDocAccess")
+	res, err := u.next.ChangeDocAccess(ctx, userId, request)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetDocAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.DocAcl, error) {
+	ctx, end := startSpan(ctx, "GetDocAccess")
+	res, err := u.next.GetDocAccess(ctx, userId, docId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) RevokeDocAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error {
+	ctx, end := startSpan(ctx, "RevokeDocAccess")
+	err := u.next.RevokeDocAccess(ctx, userId, request)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) ReplaceDocAccess(ctx context.Context, userId data.Id, acl data.DocAcl) (*data.DocAcl, error) {
+	ctx, end := startSpan(ctx, "ReplaceDocAccess")
+	res, err := u.next.ReplaceDocAccess(ctx, userId, acl)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) ExplainDocAccess(ctx context.Context, userId data.Id, docId data.Id, targetId data.Id) (*data.AccessExplanation, error) {
+	ctx, end := startSpan(ctx, "ExplainDocAccess")
+	res, err := u.next.ExplainDocAccess(ctx, userId, docId, targetId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) LaunchLinter(ctx context.Context, userId data.Id, docId data.Id) error {
+	ctx, end := startSpan(ctx, "LaunchLinter")
+	err := u.next.LaunchLinter(ctx, userId, docId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) GetAllDocs(ctx context.Context, userId data.Id, filter DocFilter, page PageRequest) ([]data.Doc, string, error) {
+	ctx, end := startSpan(ctx, "GetAllDocs")
+	res, next, err := u.next.GetAllDocs(ctx, userId, filter, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) Search(ctx context.Context, userId data.Id, request SearchRequest, page PageRequest) ([]data.SearchResult, string, error) {
+	ctx, end := startSpan(ctx, "Search")
+	res, next, err := u.next.Search(ctx, userId, request, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page PageRequest) ([]data.Symbol, string, error) {
+	ctx, end := startSpan(ctx, "FindSymbols")
+	res, next, err := u.next.FindSymbols(ctx, userId, query, kind, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) GetDocOutline(ctx context.Context, userId data.Id, docId data.Id, page PageRequest) ([]data.Symbol, string, error) {
+	ctx, end := startSpan(ctx, "GetDocOutline")
+	res, next, err := u.next.GetDocOutline(ctx, userId, docId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) GetUserById(ctx context.Context, userId data.Id) (*data.User, error) {
+	ctx, end := startSpan(ctx, "GetUserById")
+	res, err := u.next.GetUserById(ctx, userId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) EditUser(ctx context.Context, userId data.Id, newUser data.User) (*data.User, error) {
+	ctx, end := startSpan(ctx, "EditUser")
+	res, err := u.next.EditUser(ctx, userId, newUser)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) CreateGroup(ctx context.Context, userId data.Id, group data.Group) (*data.Group, error) {
+	ctx, end := startSpan(ctx, "CreateGroup")
+	res, err := u.next.CreateGroup(ctx, userId, group)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) DeleteGroup(ctx context.Context, userId data.Id, groupId data.Id) error {
+	ctx, end := startSpan(ctx, "DeleteGroup")
+	err := u.next.DeleteGroup(ctx, userId, groupId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) EditGroup(ctx context.Context, userId data.Id, newGroup data.Group) (*data.Group, error) {
+	ctx, end := startSpan(ctx, "EditGroup")
+	res, err := u.next.EditGroup(ctx, userId, newGroup)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) AddMember(ctx context.Context, userId data.Id, groupId data.Id, MemberId data.Id) error {
+	ctx, end := startSpan(ctx, "AddMember")
+	err := u.next.AddMember(ctx, userId, groupId, MemberId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) RemoveMember(ctx context.Context, userId data.Id, groupId data.Id, memberId data.Id) error {
+	ctx, end := startSpan(ctx, "RemoveMember")
+	err := u.next.RemoveMember(ctx, userId, groupId, memberId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) GetMembers(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Member, string, error) {
+	ctx, end := startSpan(ctx, "GetMembers")
+	res, next, err := u.next.GetMembers(ctx, userId, groupId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) SetMemberRole(ctx context.Context, userId data.Id, request RoleRequest) error {
+	ctx, end := startSpan(ctx, "SetMemberRole")
+	err := u.next.SetMemberRole(ctx, userId, request)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) TransferGroupOwnership(ctx context.Context, userId data.Id, groupId data.Id, newOwnerId data.Id) error {
+	ctx, end := startSpan(ctx, "TransferGroupOwnership")
+	err := u.next.TransferGroupOwnership(ctx, userId, groupId, newOwnerId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) AddSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error {
+	ctx, end := startSpan(ctx, "AddSubgroup")
+	err := u.next.AddSubgroup(ctx, userId, groupId, subgroupId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) RemoveSubgroup(ctx context.Context, userId data.Id, groupId data.Id, subgroupId data.Id) error {
+	ctx, end := startSpan(ctx, "RemoveSubgroup")
+	err := u.next.RemoveSubgroup(ctx, userId, groupId, subgroupId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) GetSubgroups(ctx context.Context, userId data.Id, groupId data.Id, page PageRequest) ([]data.Group, string, error) {
+	ctx, end := startSpan(ctx, "GetSubgroups")
+	res, next, err := u.next.GetSubgroups(ctx, userId, groupId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) CreateOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error) {
+	ctx, end := startSpan(ctx, "CreateOrg")
+	res, err := u.next.CreateOrg(ctx, userId, org)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetOrg(ctx context.Context, userId data.Id, orgId data.Id) (*data.Org, error) {
+	ctx, end := startSpan(ctx, "GetOrg")
+	res, err := u.next.GetOrg(ctx, userId, orgId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) EditOrg(ctx context.Context, userId data.Id, org data.Org) (*data.Org, error) {
+	ctx, end := startSpan(ctx, "EditOrg")
+	res, err := u.next.EditOrg(ctx, userId, org)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) DeleteOrg(ctx context.Context, userId data.Id, orgId data.Id) error {
+	ctx, end := startSpan(ctx, "DeleteOrg")
+	err := u.next.DeleteOrg(ctx, userId, orgId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) GetUserOrgs(ctx context.Context, userId data.Id, page PageRequest) ([]data.Org, string, error) {
+	ctx, end := startSpan(ctx, "GetUserOrgs")
+	res, next, err := u.next.GetUserOrgs(ctx, userId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Doc, string, error) {
+	ctx, end := startSpan(ctx, "GetOrgDocs")
+	res, next, err := u.next.GetOrgDocs(ctx, userId, orgId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) AddOrgMember(ctx context.Context, userId data.Id, request OrgMemberRequest) error {
+	ctx, end := startSpan(ctx, "AddOrgMember")
+	err := u.next.AddOrgMember(ctx, userId, request)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) SetOrgMemberRole(ctx context.Context, userId data.Id, request OrgMemberRequest) error {
+	ctx, end := startSpan(ctx, "SetOrgMemberRole")
+	err := u.next.SetOrgMemberRole(ctx, userId, request)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) RemoveOrgMember(ctx context.Context, userId data.Id, orgId data.Id, memberId data.Id) error {
+	ctx, end := startSpan(ctx, "RemoveOrgMember")
+	err := u.next.RemoveOrgMember(ctx, userId, orgId, memberId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) GetOrgMembers(ctx context.Context, userId data.Id, orgId data.Id, page PageRequest) ([]data.Member, string, error) {
+	ctx, end := startSpan(ctx, "GetOrgMembers")
+	res, next, err := u.next.GetOrgMembers(ctx, userId, orgId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) CreateFolder(ctx context.Context, userId data.Id, folder data.Folder) (*data.Folder, error) {
+	ctx, end := startSpan(ctx, "CreateFolder")
+	res, err := u.next.CreateFolder(ctx, userId, folder)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetFolder(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) (*data.FolderContents, error) {
+	ctx, end := startSpan(ctx, "GetFolder")
+	res, err := u.next.GetFolder(ctx, userId, folderId, page)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page PageRequest) ([]data.Folder, string, error) {
+	ctx, end := startSpan(ctx, "GetSubfolders")
+	res, next, err := u.next.GetSubfolders(ctx, userId, folderId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) GetUserFolders(ctx context.Context, userId data.Id, page PageRequest) ([]data.Folder, string, error) {
+	ctx, end := startSpan(ctx, "GetUserFolders")
+	res, next, err := u.next.GetUserFolders(ctx, userId, page)
+	end(err)
+	return res, next, err
+}
+
+func (u tracedUseCases) RenameFolder(ctx context.Context, userId data.Id, folderId data.Id, name string) (*data.Folder, error) {
+	ctx, end := startSpan(ctx, "RenameFolder")
+	res, err := u.next.RenameFolder(ctx, userId, folderId, name)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) MoveFolder(ctx context.Context, userId data.Id, folderId data.Id, parentId data.Id) (*data.Folder, error) {
+	ctx, end := startSpan(ctx, "MoveFolder")
+	res, err := u.next.MoveFolder(ctx, userId, folderId, parentId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) DeleteFolder(ctx context.Context, userId data.Id, folderId data.Id) error {
+	ctx, end := startSpan(ctx, "DeleteFolder")
+	err := u.next.DeleteFolder(ctx, userId, folderId)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) MoveDoc(ctx context.Context, userId data.Id, docId data.Id, folderId data.Id) (*data.Doc, error) {
+	ctx, end := startSpan(ctx, "MoveDoc")
+	res, err := u.next.MoveDoc(ctx, userId, docId, folderId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) ChangeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) (*data.FolderAcl, error) {
+	ctx, end := startSpan(ctx, "ChangeFolderAccess")
+	res, err := u.next.ChangeFolderAccess(ctx, userId, request)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) GetFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (*data.FolderAcl, error) {
+	ctx, end := startSpan(ctx, "GetFolderAccess")
+	res, err := u.next.GetFolderAccess(ctx, userId, folderId)
+	end(err)
+	return res, err
+}
+
+func (u tracedUseCases) RevokeFolderAccess(ctx context.Context, userId data.Id, request DocAccessRequest) error {
+	ctx, end := startSpan(ctx, "RevokeFolderAccess")
+	err := u.next.RevokeFolderAccess(ctx, userId, request)
+	end(err)
+	return err
+}
+
+func (u tracedUseCases) CheckReadiness(ctx context.Context) data.Readiness {
+	ctx, end := startSpan(ctx, "CheckReadiness")
+	res := u.next.CheckReadiness(ctx)
+	end(nil)
+	return res
+}
+
+func (u tracedUseCases) IsAdmin(ctx context.Context, userId data.Id) (bool, error) {
+	ctx, end := startSpan(ctx, "IsAdmin")
+	ok, err := u.next.IsAdmin(ctx, userId)
+	end(err)
+	return ok, err
+}
+
+func (u tracedUseCases) GetLintJobs(ctx context.Context) []data.LintJob {
+	ctx, end := startSpan(ctx, "GetLintJobs")
+	res := u.next.GetLintJobs(ctx)
+	end(nil)
+	return res
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"doccer/config"
+	"doccer/data"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DocValidator checks requests and docs against the configured limits and
+// the languages the linter knows. The api bounds request bodies with it and
+// the model checks every doc it stores.
+type DocValidator struct {
+	limits    config.LimitsConfig
+	languages map[string]bool
+	allowed   string
+}
+
+func NewDocValidator(limits config.LimitsConfig, languages []string) *DocValidator {
+	v := &DocValidator{
+		limits:    limits,
+		languages: map[string]bool{},
+		allowed:   strings.Join(languages, ", "),
+	}
+	for _, lang := range languages {
+		v.languages[lang] = true
+	}
+	return v
+}
+
+// MaxBodyBytes is the size limit of request bodies, 0 if there is none.
+func (v *DocValidator) MaxBodyBytes() int64 {
+	return int64(v.limits.MaxBodyBytes)
+}
+
+// ValidateDoc checks a new doc, or an edit of old. Only the text and the
+// language changed by an edit are checked, so docs stored before a limit
+// was lowered or a language turned off can still be edited.
+func (v *DocValidator) ValidateDoc(doc data.Doc, old *data.Doc) error {
+	textChanged := old == nil || doc.Text != old.Text
+	if textChanged && v.limits.MaxDocBytes > 0 && len(doc.Text) > v.limits.MaxDocBytes {
+		return &Error{
+			Code:    ErrTooLarge.Code,
+			Message: "doc too large",
+			Details: []FieldError{{Field: "text", Message: fmt.Sprintf("must be at most %d bytes", v.limits.MaxDocBytes)}},
+		}
+	}
+
+	var details []FieldError
+	if textChanged {
+		if msg := checkText(doc.Text); msg != "" {
+			details = append(details, FieldError{Field: "text", Message: msg})
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"title", doc.Title, v.limits.MaxTitleBytes},
+		{"description", doc.Description, v.limits.MaxDescriptionBytes},
+	} {
+		switch {
+		case !utf8.ValidString(field.value):
+			details = append(details, FieldError{Field: field.name, Message: "must be valid UTF-8"})
+		case field.max > 0 && len(field.value) > field.max:
+			details = append(details, FieldError{Field: field.name, Message: fmt.Sprintf("must be at most %d bytes", field.max)})
+		}
+	}
+	langChanged := old == nil || doc.Lang != old.Lang
+	if langChanged && doc.Lang != "" && !v.languages[doc.Lang] {
+		details = append(details, FieldError{Field: "lang", Message: "must be empty or one of " + v.allowed})
+	}
+	if !doc.Access.Valid() {
+		details = append(details, FieldError{Field: "access", Message: data.ErrUnknownAccessLevel.Error()})
+	}
+	if len(details) > 0 {
+		return ValidationError(details...)
+	}
+	return nil
+}
+
+// checkText tells why text is not UTF-8 text, or returns "" if it is.
+// Control characters other than whitespace mean binary content.
+func checkText(text string) string {
+	for i, r := range text {
+		switch {
+		case r == utf8.RuneError && !strings.HasPrefix(text[i:], string(utf8.RuneError)):
+			return fmt.Sprintf("must be valid UTF-8, byte %d is not", i)
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f', r == 0x7f:
+			return fmt.Sprintf("must not be binary, byte %d is control character %U", i, r)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+// Package ratelimit keeps a token bucket per client key.
+package ratelimit
+
+import (
+	"doccer/config"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how often full buckets, which are no different from new
+// ones, are forgotten.
+const sweepEvery = time.Minute
+
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func New(cfg config.BucketConfig) *Limiter {
+	limit := rate.Inf
+	if cfg.PerMinute > 0 {
+		limit = rate.Limit(cfg.PerMinute / 60)
+	}
+	return &Limiter{
+		limit:   limit,
+		burst:   cfg.Burst,
+		buckets: map[string]*rate.Limiter{},
+	}
+}
+
+func (l *Limiter) bucket(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepEvery {
+		for k, b := range l.buckets {
+			if b.TokensAt(now) >= float64(l.burst) {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// Allow takes a token for key from every limiter. If one of them has none
+// left, it takes none and returns how long to wait for the tokens.
+func Allow(key string, limiters ...*Limiter) (time.Duration, bool) {
+	now := time.Now()
+	var reservations []*rate.Reservation
+	var wait time.Duration
+	for _, l := range limiters {
+		if l.limit == rate.Inf {
+			continue
+		}
+		r := l.bucket(key, now).ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if d := r.DelayFrom(now); d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return 0, true
+	}
+	for _, r := range reservations {
+		r.CancelAt(now)
+	}
+	return wait, false
+}
@@ -1,42 +1,60 @@
+//go:build ignore
+
+// A demo of the client; run it with `go run runClient.go` against a local server.
 package main
 
 import (
+	"context"
 	client2 "doccer/client"
+	"doccer/data"
+	"doccer/model"
 	"time"
 )
 
 func main() {
-	client := client2.NewClient("http://localhost:8080")
+	ctx := context.Background()
+	jacob := client2.NewClient("http://localhost:8080")
+	kurt := client2.NewClient("http://localhost:8080")
+	jordan := client2.NewClient("http://localhost:8080")
 
-	id1, _ := client.Register("Jacob", "abacaba")
-	id2, _ := client.Register("Kurt", "qwerty")
-	id3, _ := client.Register("Jordan", "zxc")
+	user1, _ := jacob.Register(ctx, "Jacob", "abacaba")
+	user2, _ := kurt.Register(ctx, "Kurt", "qwerty")
+	user3, _ := jordan.Register(ctx, "Jordan", "zxc")
 
-	println(id1, id2, id3)
+	println(user1.Id, user2.Id, user3.Id)
 
-	jwt1, _ := client.Login("Jacob", "abacaba")
-	jwt2, _ := client.Login("Kurt", "qwerty")
-	jwt3, _ := client.Login("Jordan", "zxc")
+	_, _ = jacob.Login(ctx, "Jacob", "abacaba")
+	_, _ = kurt.Login(ctx, "Kurt", "qwerty")
+	_, _ = jordan.Login(ctx, "Jordan", "zxc")
 
-	groupId, _ := client.CreateGroup("Converge", jwt1)
-	println(groupId)
+	group, _ := jacob.CreateGroup(ctx, data.Group{Name: "Converge"})
+	println(group.Id)
 
-	_ = client.AddMember(groupId, id2, jwt1)
+	_ = jacob.AddMember(ctx, group.Id, user2.Id)
 
-	docId, _ := client.CreateDoc("Jane Doe", "Text", "none", jwt1)
-	println(docId)
+	doc, _ := jacob.CreateDoc(ctx, data.Doc{Text: "Jane Doe", Lang: "Text", Access: data.AccessNone})
+	println(doc.Id)
 
-	_ = client.ChangeGroupAccess(docId, groupId, "absolute", jwt1)
+	_, _ = jacob.ChangeDocAccess(ctx, model.DocAccessRequest{
+		DocId:  doc.Id,
+		Type:   model.GroupAccess,
+		ItemId: group.Id,
+		Access: data.AccessAbsolute,
+	})
 
-	doc, _ := client.GetDoc(docId, jwt3)
-	if doc != nil {
+	if _, err := jordan.GetDoc(ctx, doc.Id); err == nil {
 		println("This doc shouldn't be accessible")
 	}
 
-	_ = client.ChangeMemberAccess(docId, id3, "read", jwt2)
+	_, _ = kurt.ChangeDocAccess(ctx, model.DocAccessRequest{
+		DocId:  doc.Id,
+		Type:   model.MemberAccess,
+		ItemId: user3.Id,
+		Access: data.AccessRead,
+	})
 
-	doc, _ = client.GetDoc(docId, jwt3)
-	println(doc.Text, doc.Lang, doc.LinterStatus)
+	got, _ := jordan.GetDoc(ctx, doc.Id)
+	println(got.Text, got.Lang, got.LinterStatus)
 
 
 	code := `
@@ -47,13 +65,14 @@ func F() {
 }
 
 func main() {
+	ctx := context.Background()
     F()
 }
 `
-	codeId, _ := client.CreateDoc(code, "go", "read", jwt2)
+	codeDoc, _ := kurt.CreateDoc(ctx, data.Doc{Text: code, Lang: "go", Access: data.AccessRead})
 	time.Sleep(2 * time.Second)
-	doc, _ = client.GetDoc(codeId, jwt1)
-	println(doc.Text)
-	println("Lang: ", doc.Lang)
-	println("Inspection: ", doc.LinterStatus)
+	got, _ = jacob.GetDoc(ctx, codeDoc.Id)
+	println(got.Text)
+	println("Lang: ", got.Lang)
+	println("Inspection: ", got.LinterStatus)
 }
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// benchBase is the first id of the rows the benchmarks add, far above
+// the ids of real data.
+const benchBase = 1000000
+
+// openBenchDB connects to the database in DOCCER_TEST_DSN, which must have
+// the schema of initdb.sql.
+func openBenchDB(b *testing.B) *PostgresStorage {
+	dsn := os.Getenv("DOCCER_TEST_DSN")
+	if dsn == "" {
+		b.Skip("DOCCER_TEST_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+	return &PostgresStorage{Dbc: db}
+}
+
+// seedGroupDoc adds a doc shared with a group of members users, which is a
+// subgroup of the group the doc is granted to when nested is set.
+func seedGroupDoc(b *testing.B, p *PostgresStorage, members int, nested bool) {
+	ctx := context.Background()
+	cleanup := []string{
+		"delete from DocGroupRestriction where doc_id = $1",
+		"delete from Docs where id = $1",
+		"delete from GroupSubgroup where group_id = $1",
+		"delete from GroupMember where group_id between $1 and $1 + 1",
+		"delete from Groups1 where id between $1 and $1 + 1",
+		"delete from Users where id between $1 and $1 + 100000",
+	}
+	clear := func() {
+		for _, stmt := range cleanup {
+			if _, err := p.Dbc.ExecContext(ctx, stmt, benchBase); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	clear()
+	b.Cleanup(clear)
+
+	memberGroup := benchBase
+	if nested {
+		memberGroup = benchBase + 1
+	}
+	for _, step := range []struct {
+		stmt string
+		args []interface{}
+	}{
+		{"insert into Users (id, login) select g, 'bench' || g from generate_series($1::int, $1 + $2) g", []interface{}{benchBase, members}},
+		{"insert into Groups1 (id, creator_id, name) values ($1, $1, 'bench'), ($1 + 1, $1, 'bench-sub')", []interface{}{benchBase}},
+		{"insert into GroupMember (group_id, member_id) select $3::int, g from generate_series($1 + 1, $1 + $2) g", []interface{}{benchBase, members, memberGroup}},
+		{"insert into GroupSubgroup (group_id, subgroup_id) select $1, $1 + 1 where $2", []interface{}{benchBase, nested}},
+		{"insert into Docs (id, creator_id, text, public_access_type, lang, lstatus) values ($1, $1, '', 0, 'Text', '')", []interface{}{benchBase}},
+		{"insert into DocGroupRestriction (doc_id, group_id, type) values ($1, $1, $2)", []interface{}{benchBase, int(data.AccessEdit)}},
+	} {
+		if _, err := p.Dbc.ExecContext(ctx, step.stmt, step.args...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckAccess(b *testing.B) {
+	p := openBenchDB(b)
+	for _, members := range []int{1000, 10000} {
+		for _, nested := range []bool{false, true} {
+			b.Run(fmt.Sprintf("members=%d/nested=%t", members, nested), func(b *testing.B) {
+				seedGroupDoc(b, p, members, nested)
+				ctx := context.Background()
+				docId := data.Id(fmt.Sprint(benchBase))
+				member := data.Id(fmt.Sprint(benchBase + members/2))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					access, err := p.CheckAccess(ctx, member, docId)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if access != data.AccessEdit {
+						b.Fatalf("access = %v, want edit", access)
+					}
+				}
+			})
+		}
+	}
+}
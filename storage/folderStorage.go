@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"doccer/model"
+	"strconv"
+)
+
+// folderAccessExpr is the access of user $2 to the folder f.
+const folderAccessExpr = "coalesce((select max(g.access) from folder_access_grants(f.id, $2) g), 0)"
+
+const folderColumns = "f.id, f.parent_id, f.creator_id, f.org_id, f.name"
+
+func scanFolder(row rowScanner, extra ...interface{}) (*data.Folder, error) {
+	folder := data.Folder{}
+	dest := []interface{}{&folder.Id, &folder.ParentId, &folder.Creator, &folder.OrgId, &folder.Name}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (p *PostgresStorage) GenerateNewFolderId(ctx context.Context) data.Id {
+	p.mu5.Lock()
+	defer p.mu5.Unlock()
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		p.log.ErrorContext(ctx, "generating id failed", "error", err)
+		return ""
+	}
+
+	row := tx.QueryRowContext(ctx, "select g.last_folder_id from GeneralInfo g where g.base_id = 0")
+
+	lastId := 0
+	_ = row.Scan(&lastId)
+	id := strconv.Itoa(lastId)
+	lastId += 1
+
+	_, _ = tx.ExecContext(ctx, "update GeneralInfo set last_folder_id = $1 where base_id = 0", lastId)
+
+	_ = tx.Commit()
+
+	return data.Id(id)
+}
+
+func (p *PostgresStorage) CheckFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (data.AccessLevel, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select "+folderAccessExpr+" from Folders f where f.id = $1", folderId, userId)
+	access := data.AccessNone
+	err := res.Scan(&access)
+	if err != nil {
+		return data.AccessNone, model.ErrNotFound
+	}
+	return access, nil
+}
+
+func (p *PostgresStorage) CreateFolder(ctx context.Context, folder data.Folder) (*data.Folder, error) {
+	_, err := p.Dbc.ExecContext(ctx, "insert into Folders values ($1, $2, $3, $4, $5)",
+		folder.Id, nullId(folder.ParentId), folder.Creator, nullId(folder.OrgId), folder.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (p *PostgresStorage) GetFolder(ctx context.Context, folderId data.Id) (*data.Folder, error) {
+	folder, err := scanFolder(p.Dbc.QueryRowContext(ctx, "select "+folderColumns+" from Folders f where f.id = $1", folderId))
+	if err != nil {
+		return nil, model.ErrNotFound
+	}
+	return folder, nil
+}
+
+func (p *PostgresStorage) RenameFolder(ctx context.Context, folderId data.Id, name string) error {
+	res, err := p.Dbc.ExecContext(ctx, "update Folders set name = $1 where id = $2", name, folderId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// MoveFolder puts the folder under parentId, or at the top level if it is
+// empty. Under a parent the folder and its subfolders take the parent's
+// org, as they would have been created with it.
+func (p *PostgresStorage) MoveFolder(ctx context.Context, folderId data.Id, parentId data.Id) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, "update Folders set parent_id = $1 where id = $2", nullId(parentId), folderId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	if parentId != "" {
+		_, err := tx.ExecContext(ctx, `
+with recursive subtree(id) as (
+	select $1::int
+	union
+	select f.id from Folders f join subtree s on f.parent_id = s.id
+)
+update Folders set org_id = (select org_id from Folders where id = $2)
+where id in (select id from subtree)`, folderId, parentId)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteFolder removes the folder with its subfolders; docs inside
+// are moved out to the top level.
+func (p *PostgresStorage) DeleteFolder(ctx context.Context, folderId data.Id) error {
+	res, err := p.Dbc.ExecContext(ctx, "delete from Folders where id = $1", folderId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// FolderContains reports whether descendantId is nested in folderId at any depth.
+func (p *PostgresStorage) FolderContains(ctx context.Context, folderId data.Id, descendantId data.Id) (bool, error) {
+	res := p.Dbc.QueryRowContext(ctx, `
+with recursive descendants(id) as (
+	select f.id from Folders f where f.parent_id = $1
+	union
+	select f.id from Folders f join descendants d on f.parent_id = d.id
+)
+select exists(select 1 from descendants where id = $2)`, folderId, descendantId)
+	contains := false
+	err := res.Scan(&contains)
+	return contains, err
+}
+
+func (p *PostgresStorage) GetUserFolders(ctx context.Context, userId data.Id, page model.PageRequest) ([]data.Folder, string, error) {
+	folders := []data.Folder{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"f.id"}}, folderColumns, "Folders f",
+		[]string{"f.creator_id = $1", "f.parent_id is null"}, []interface{}{userId}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			folder, err := scanFolder(res, keys...)
+			if err != nil {
+				return err
+			}
+			folders = append(folders, *folder)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return folders, next, nil
+}
+
+// GetFolderContents lists the first page of subfolders of the folder and a
+// page of its docs that userId can read. The cursor of page only applies to
+// the docs; further subfolders are listed by GetSubfolders.
+func (p *PostgresStorage) GetFolderContents(ctx context.Context, userId data.Id, folderId data.Id, page model.PageRequest) (*data.FolderContents, error) {
+	folder, err := p.GetFolder(ctx, folderId)
+	if err != nil {
+		return nil, err
+	}
+	folders, foldersNext, err := p.GetSubfolders(ctx, userId, folderId, model.PageRequest{Limit: page.Limit})
+	if err != nil {
+		return nil, err
+	}
+	docs, next, err := p.queryDocPage(ctx, docIdKeyset, "Docs d", []string{"d.folder_id = $1", effectiveAccessExpr + " >= 1"},
+		[]interface{}{folderId, userId}, page)
+	if err != nil {
+		return nil, err
+	}
+	return &data.FolderContents{
+		Folder:      *folder,
+		Folders:     folders,
+		FoldersNext: foldersNext,
+		Docs:        docs,
+		Next:        next,
+	}, nil
+}
+
+// GetSubfolders lists a page of the subfolders of the folder that userId can
+// read.
+func (p *PostgresStorage) GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page model.PageRequest) ([]data.Folder, string, error) {
+	folders := []data.Folder{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"f.id"}}, folderColumns, "Folders f",
+		[]string{"f.parent_id = $1", folderAccessExpr + " >= 1"}, []interface{}{folderId, userId}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			folder, err := scanFolder(res, keys...)
+			if err != nil {
+				return err
+			}
+			folders = append(folders, *folder)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return folders, next, nil
+}
+
+func (p *PostgresStorage) MoveDoc(ctx context.Context, docId data.Id, folderId data.Id) error {
+	res, err := p.Dbc.ExecContext(ctx, "update Docs set folder_id = $1 where id = $2", nullId(folderId), docId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) EditFolderAccess(ctx context.Context, folderId data.Id, editRequest model.DocAccessRequest) error {
+	query := "insert into FolderMemberRestriction values ($1, $2, $3) on conflict(folder_id, member_id) do update set type = excluded.type"
+	if editRequest.Type == model.GroupAccess {
+		query = "insert into FolderGroupRestriction values ($1, $2, $3) on conflict(folder_id, group_id) do update set type = excluded.type"
+	}
+	_, err := p.Dbc.ExecContext(ctx, query, folderId, editRequest.ItemId, editRequest.Access)
+	return err
+}
+
+func (p *PostgresStorage) DeleteFolderAccess(ctx context.Context, folderId data.Id, request model.DocAccessRequest) error {
+	query := "delete from FolderMemberRestriction where folder_id = $1 and member_id = $2"
+	if request.Type == model.GroupAccess {
+		query = "delete from FolderGroupRestriction where folder_id = $1 and group_id = $2"
+	}
+	res, err := p.Dbc.ExecContext(ctx, query, folderId, request.ItemId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetFolderAcl(ctx context.Context, folderId data.Id) (*data.FolderAcl, error) {
+	acl := data.FolderAcl{
+		FolderId: folderId,
+		Members:  []data.MemberGrant{},
+		Groups:   []data.GroupGrant{},
+	}
+
+	members, err := p.Dbc.QueryContext(ctx, "select m.member_id, u.login, m.type from FolderMemberRestriction m "+
+		"join Users u on u.id = m.member_id where m.folder_id = $1 order by m.member_id", folderId)
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+	for members.Next() {
+		grant := data.MemberGrant{}
+		if err := members.Scan(&grant.UserId, &grant.Login, &grant.Access); err != nil {
+			return nil, err
+		}
+		acl.Members = append(acl.Members, grant)
+	}
+
+	groups, err := p.Dbc.QueryContext(ctx, "select r.group_id, g.name, r.type from FolderGroupRestriction r "+
+		"join Groups1 g on g.id = r.group_id where r.folder_id = $1 order by r.group_id", folderId)
+	if err != nil {
+		return nil, err
+	}
+	defer groups.Close()
+	for groups.Next() {
+		grant := data.GroupGrant{}
+		if err := groups.Scan(&grant.GroupId, &grant.Name, &grant.Access); err != nil {
+			return nil, err
+		}
+		acl.Groups = append(acl.Groups, grant)
+	}
+	return &acl, nil
+}
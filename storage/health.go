@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SchemaVersion is the version of initdb.sql this code expects.
+const SchemaVersion = 1
+
+func (p *PostgresStorage) Ping(ctx context.Context) error {
+	return p.Dbc.PingContext(ctx)
+}
+
+// CheckSchemaVersion fails unless the database was made by the initdb.sql
+// of this code. Databases from before the SchemaVersion table count as a
+// mismatch, not as an outage.
+func (p *PostgresStorage) CheckSchemaVersion(ctx context.Context) error {
+	version := 0
+	err := p.Dbc.QueryRowContext(ctx, "select version from SchemaVersion").Scan(&version)
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		return fmt.Errorf("the database has no schema version, want %d; recreate it with initdb.sql", SchemaVersion)
+	}
+	if err != nil {
+		return err
+	}
+	if version != SchemaVersion {
+		return fmt.Errorf("schema version is %d, want %d; recreate the database with initdb.sql", version, SchemaVersion)
+	}
+	return nil
+}
+
+// WaitForDatabase pings the database until it answers or ctx is done.
+func (p *PostgresStorage) WaitForDatabase(ctx context.Context) error {
+	for {
+		err := p.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		p.log.InfoContext(ctx, "waiting for the database", "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable: %w", err)
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package storage
+
+import (
+	"context"
+	"doccer/data"
+	"doccer/metrics"
+	"doccer/model"
+	"doccer/tracing"
+	"go.opentelemetry.io/otel"
+	"time"
+)
+
+var tracer = otel.Tracer("doccer/storage")
+
+// instrumentedStorage times and traces the calls of every Storage method.
+type instrumentedStorage struct {
+	next model.Storage
+}
+
+// Instrument wraps s to report its calls as metrics and spans.
+func Instrument(s model.Storage) model.Storage {
+	return instrumentedStorage{next: s}
+}
+
+func observe(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "storage."+method)
+	return ctx, func(err error) {
+		metrics.ObserveStorageCall(method, start)
+		tracing.End(span, err)
+	}
+}
+
+func (s instrumentedStorage) Ping(ctx context.Context) error {
+	ctx, end := observe(ctx, "Ping")
+	err := s.next.Ping(ctx)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) CheckSchemaVersion(ctx context.Context) error {
+	ctx, end := observe(ctx, "CheckSchemaVersion")
+	err := s.next.CheckSchemaVersion(ctx)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetUser(ctx context.Context, userId data.Id) (*data.User, error) {
+	ctx, end := observe(ctx, "GetUser")
+	res, err := s.next.GetUser(ctx, userId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetUserByLogin(ctx context.Context, login string) (*data.User, error) {
+	ctx, end := observe(ctx, "GetUserByLogin")
+	res, err := s.next.GetUserByLogin(ctx, login)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetHashedPassword(ctx context.Context, userId data.Id) (*model.Password, error) {
+	ctx, end := observe(ctx, "GetHashedPassword")
+	res, err := s.next.GetHashedPassword(ctx, userId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) AddUser(ctx context.Context, newUser data.User, password model.Password) error {
+	ctx, end := observe(ctx, "AddUser")
+	err := s.next.AddUser(ctx, newUser, password)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) EditUser(ctx context.Context, newUser data.User) (*data.User, error) {
+	ctx, end := observe(ctx, "EditUser")
+	res, err := s.next.EditUser(ctx, newUser)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) SetPassword(ctx context.Context, userId data.Id, password model.Password) error {
+	ctx, end := observe(ctx, "SetPassword")
+	err := s.next.SetPassword(ctx, userId, password)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) CheckLoginExists(ctx context.Context, login string) bool {
+	ctx, end := observe(ctx, "CheckLoginExists")
+	ok := s.next.CheckLoginExists(ctx, login)
+	end(nil)
+	return ok
+}
+
+func (s instrumentedStorage) CheckAccess(ctx context.Context, userId data.Id, docId data.Id) (data.AccessLevel, error) {
+	ctx, end := observe(ctx, "CheckAccess")
+	level, err := s.next.CheckAccess(ctx, userId, docId)
+	end(err)
+	return level, err
+}
+
+func (s instrumentedStorage) GetAccessGrants(ctx context.Context, userId data.Id, docId data.Id) ([]data.AccessGrant, error) {
+	ctx, end := observe(ctx, "GetAccessGrants")
+	res, err := s.next.GetAccessGrants(ctx, userId, docId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetDoc(ctx context.Context, docId data.Id) (*data.Doc, error) {
+	ctx, end := observe(ctx, "GetDoc")
+	res, err := s.next.GetDoc(ctx, docId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetDocWithAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, data.AccessLevel, error) {
+	ctx, end := observe(ctx, "GetDocWithAccess")
+	res, level, err := s.next.GetDocWithAccess(ctx, userId, docId)
+	end(err)
+	return res, level, err
+}
+
+func (s instrumentedStorage) AddDoc(ctx context.Context, newDoc data.Doc) (*data.Id, error) {
+	ctx, end := observe(ctx, "AddDoc")
+	res, err := s.next.AddDoc(ctx, newDoc)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) EditDoc(ctx context.Context, newDoc data.Doc) (*data.Doc, error) {
+	ctx, end := observe(ctx, "EditDoc")
+	res, err := s.next.EditDoc(ctx, newDoc)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) EditDocAccess(ctx context.Context, docId data.Id, request model.DocAccessRequest) error {
+	ctx, end := observe(ctx, "EditDocAccess")
+	err := s.next.EditDocAccess(ctx, docId, request)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) DeleteDocAccess(ctx context.Context, docId data.Id, request model.DocAccessRequest) error {
+	ctx, end := observe(ctx, "DeleteDocAccess")
+	err := s.next.DeleteDocAccess(ctx, docId, request)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetDocAcl(ctx context.Context, docId data.Id) (*data.DocAcl, error) {
+	ctx, end := observe(ctx, "GetDocAcl")
+	res, err := s.next.GetDocAcl(ctx, docId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) ReplaceDocAcl(ctx context.Context, acl data.DocAcl) error {
+	ctx, end := observe(ctx, "ReplaceDocAcl")
+	err := s.next.ReplaceDocAcl(ctx, acl)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) DeleteDoc(ctx context.Context, docId data.Id) error {
+	ctx, end := observe(ctx, "DeleteDoc")
+	err := s.next.DeleteDoc(ctx, docId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) SetLinterStatus(ctx context.Context, docId data.Id, version int, status string) error {
+	ctx, end := observe(ctx, "SetLinterStatus")
+	err := s.next.SetLinterStatus(ctx, docId, version, status)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetUserUsage(ctx context.Context, userId data.Id) (*data.Usage, error) {
+	ctx, end := observe(ctx, "GetUserUsage")
+	res, err := s.next.GetUserUsage(ctx, userId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetAllDocs(ctx context.Context, userId data.Id, filter model.DocFilter, page model.PageRequest) ([]data.Doc, string, error) {
+	ctx, end := observe(ctx, "GetAllDocs")
+	res, next, err := s.next.GetAllDocs(ctx, userId, filter, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) SearchDocs(ctx context.Context, userId data.Id, request model.SearchRequest, page model.PageRequest) ([]data.SearchResult, string, error) {
+	ctx, end := observe(ctx, "SearchDocs")
+	res, next, err := s.next.SearchDocs(ctx, userId, request, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) ReplaceDocSymbols(ctx context.Context, docId data.Id, version int, symbols []data.Symbol) error {
+	ctx, end := observe(ctx, "ReplaceDocSymbols")
+	err := s.next.ReplaceDocSymbols(ctx, docId, version, symbols)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetDocSymbols(ctx context.Context, docId data.Id, page model.PageRequest) ([]data.Symbol, string, error) {
+	ctx, end := observe(ctx, "GetDocSymbols")
+	res, next, err := s.next.GetDocSymbols(ctx, docId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page model.PageRequest) ([]data.Symbol, string, error) {
+	ctx, end := observe(ctx, "FindSymbols")
+	res, next, err := s.next.FindSymbols(ctx, userId, query, kind, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) CreateGroup(ctx context.Context, group data.Group) (*data.Group, error) {
+	ctx, end := observe(ctx, "CreateGroup")
+	res, err := s.next.CreateGroup(ctx, group)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) DeleteGroup(ctx context.Context, groupId data.Id) error {
+	ctx, end := observe(ctx, "DeleteGroup")
+	err := s.next.DeleteGroup(ctx, groupId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) EditGroup(ctx context.Context, newGroup data.Group) (*data.Group, error) {
+	ctx, end := observe(ctx, "EditGroup")
+	res, err := s.next.EditGroup(ctx, newGroup)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetGroupById(ctx context.Context, groupId data.Id) (*data.Group, error) {
+	ctx, end := observe(ctx, "GetGroupById")
+	res, err := s.next.GetGroupById(ctx, groupId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) AddMember(ctx context.Context, groupId data.Id, newMemberId data.Id) error {
+	ctx, end := observe(ctx, "AddMember")
+	err := s.next.AddMember(ctx, groupId, newMemberId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) RemoveMember(ctx context.Context, groupId data.Id, memberId data.Id) error {
+	ctx, end := observe(ctx, "RemoveMember")
+	err := s.next.RemoveMember(ctx, groupId, memberId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetMembers(ctx context.Context, groupId data.Id, page model.PageRequest) ([]data.Member, string, error) {
+	ctx, end := observe(ctx, "GetMembers")
+	res, next, err := s.next.GetMembers(ctx, groupId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GetMemberRole(ctx context.Context, groupId data.Id, userId data.Id) (data.Role, error) {
+	ctx, end := observe(ctx, "GetMemberRole")
+	role, err := s.next.GetMemberRole(ctx, groupId, userId)
+	end(err)
+	return role, err
+}
+
+func (s instrumentedStorage) SetMemberRole(ctx context.Context, groupId data.Id, memberId data.Id, role data.Role) error {
+	ctx, end := observe(ctx, "SetMemberRole")
+	err := s.next.SetMemberRole(ctx, groupId, memberId, role)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) TransferGroupOwnership(ctx context.Context, groupId data.Id, newOwnerId data.Id) error {
+	ctx, end := observe(ctx, "TransferGroupOwnership")
+	err := s.next.TransferGroupOwnership(ctx, groupId, newOwnerId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) AddSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	ctx, end := observe(ctx, "AddSubgroup")
+	err := s.next.AddSubgroup(ctx, groupId, subgroupId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) RemoveSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	ctx, end := observe(ctx, "RemoveSubgroup")
+	err := s.next.RemoveSubgroup(ctx, groupId, subgroupId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetSubgroups(ctx context.Context, groupId data.Id, page model.PageRequest) ([]data.Group, string, error) {
+	ctx, end := observe(ctx, "GetSubgroups")
+	res, next, err := s.next.GetSubgroups(ctx, groupId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GenerateNewUserId(ctx context.Context) data.Id {
+	ctx, end := observe(ctx, "GenerateNewUserId")
+	id := s.next.GenerateNewUserId(ctx)
+	end(nil)
+	return id
+}
+
+func (s instrumentedStorage) GenerateNewDocId(ctx context.Context) data.Id {
+	ctx, end := observe(ctx, "GenerateNewDocId")
+	id := s.next.GenerateNewDocId(ctx)
+	end(nil)
+	return id
+}
+
+func (s instrumentedStorage) GenerateNewGroupId(ctx context.Context) data.Id {
+	ctx, end := observe(ctx, "GenerateNewGroupId")
+	id := s.next.GenerateNewGroupId(ctx)
+	end(nil)
+	return id
+}
+
+func (s instrumentedStorage) GenerateNewOrgId(ctx context.Context) data.Id {
+	ctx, end := observe(ctx, "GenerateNewOrgId")
+	id := s.next.GenerateNewOrgId(ctx)
+	end(nil)
+	return id
+}
+
+func (s instrumentedStorage) CreateOrg(ctx context.Context, org data.Org, ownerId data.Id) (*data.Org, error) {
+	ctx, end := observe(ctx, "CreateOrg")
+	res, err := s.next.CreateOrg(ctx, org, ownerId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetOrg(ctx context.Context, orgId data.Id) (*data.Org, error) {
+	ctx, end := observe(ctx, "GetOrg")
+	res, err := s.next.GetOrg(ctx, orgId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) EditOrg(ctx context.Context, org data.Org) (*data.Org, error) {
+	ctx, end := observe(ctx, "EditOrg")
+	res, err := s.next.EditOrg(ctx, org)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) DeleteOrg(ctx context.Context, orgId data.Id) error {
+	ctx, end := observe(ctx, "DeleteOrg")
+	err := s.next.DeleteOrg(ctx, orgId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetUserOrgs(ctx context.Context, userId data.Id, page model.PageRequest) ([]data.Org, string, error) {
+	ctx, end := observe(ctx, "GetUserOrgs")
+	res, next, err := s.next.GetUserOrgs(ctx, userId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page model.PageRequest) ([]data.Doc, string, error) {
+	ctx, end := observe(ctx, "GetOrgDocs")
+	res, next, err := s.next.GetOrgDocs(ctx, userId, orgId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GetOrgRole(ctx context.Context, orgId data.Id, userId data.Id) (data.Role, error) {
+	ctx, end := observe(ctx, "GetOrgRole")
+	role, err := s.next.GetOrgRole(ctx, orgId, userId)
+	end(err)
+	return role, err
+}
+
+func (s instrumentedStorage) AddOrgMember(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	ctx, end := observe(ctx, "AddOrgMember")
+	err := s.next.AddOrgMember(ctx, orgId, memberId, role)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) SetOrgMemberRole(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	ctx, end := observe(ctx, "SetOrgMemberRole")
+	err := s.next.SetOrgMemberRole(ctx, orgId, memberId, role)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) RemoveOrgMember(ctx context.Context, orgId data.Id, memberId data.Id) error {
+	ctx, end := observe(ctx, "RemoveOrgMember")
+	err := s.next.RemoveOrgMember(ctx, orgId, memberId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetOrgMembers(ctx context.Context, orgId data.Id, page model.PageRequest) ([]data.Member, string, error) {
+	ctx, end := observe(ctx, "GetOrgMembers")
+	res, next, err := s.next.GetOrgMembers(ctx, orgId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GenerateNewFolderId(ctx context.Context) data.Id {
+	ctx, end := observe(ctx, "GenerateNewFolderId")
+	id := s.next.GenerateNewFolderId(ctx)
+	end(nil)
+	return id
+}
+
+func (s instrumentedStorage) CheckFolderAccess(ctx context.Context, userId data.Id, folderId data.Id) (data.AccessLevel, error) {
+	ctx, end := observe(ctx, "CheckFolderAccess")
+	level, err := s.next.CheckFolderAccess(ctx, userId, folderId)
+	end(err)
+	return level, err
+}
+
+func (s instrumentedStorage) CreateFolder(ctx context.Context, folder data.Folder) (*data.Folder, error) {
+	ctx, end := observe(ctx, "CreateFolder")
+	res, err := s.next.CreateFolder(ctx, folder)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetFolder(ctx context.Context, folderId data.Id) (*data.Folder, error) {
+	ctx, end := observe(ctx, "GetFolder")
+	res, err := s.next.GetFolder(ctx, folderId)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) RenameFolder(ctx context.Context, folderId data.Id, name string) error {
+	ctx, end := observe(ctx, "RenameFolder")
+	err := s.next.RenameFolder(ctx, folderId, name)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) MoveFolder(ctx context.Context, folderId data.Id, parentId data.Id) error {
+	ctx, end := observe(ctx, "MoveFolder")
+	err := s.next.MoveFolder(ctx, folderId, parentId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) DeleteFolder(ctx context.Context, folderId data.Id) error {
+	ctx, end := observe(ctx, "DeleteFolder")
+	err := s.next.DeleteFolder(ctx, folderId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) FolderContains(ctx context.Context, folderId data.Id, descendantId data.Id) (bool, error) {
+	ctx, end := observe(ctx, "FolderContains")
+	ok, err := s.next.FolderContains(ctx, folderId, descendantId)
+	end(err)
+	return ok, err
+}
+
+func (s instrumentedStorage) GetUserFolders(ctx context.Context, userId data.Id, page model.PageRequest) ([]data.Folder, string, error) {
+	ctx, end := observe(ctx, "GetUserFolders")
+	res, next, err := s.next.GetUserFolders(ctx, userId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) GetFolderContents(ctx context.Context, userId data.Id, folderId data.Id, page model.PageRequest) (*data.FolderContents, error) {
+	ctx, end := observe(ctx, "GetFolderContents")
+	res, err := s.next.GetFolderContents(ctx, userId, folderId, page)
+	end(err)
+	return res, err
+}
+
+func (s instrumentedStorage) GetSubfolders(ctx context.Context, userId data.Id, folderId data.Id, page model.PageRequest) ([]data.Folder, string, error) {
+	ctx, end := observe(ctx, "GetSubfolders")
+	res, next, err := s.next.GetSubfolders(ctx, userId, folderId, page)
+	end(err)
+	return res, next, err
+}
+
+func (s instrumentedStorage) MoveDoc(ctx context.Context, docId data.Id, folderId data.Id) error {
+	ctx, end := observe(ctx, "MoveDoc")
+	err := s.next.MoveDoc(ctx, docId, folderId)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) EditFolderAccess(ctx context.Context, folderId data.Id, request model.DocAccessRequest) error {
+	ctx, end := observe(ctx, "EditFolderAccess")
+	err := s.next.EditFolderAccess(ctx, folderId, request)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) DeleteFolderAccess(ctx context.Context, folderId data.Id, request model.DocAccessRequest) error {
+	ctx, end := observe(ctx, "DeleteFolderAccess")
+	err := s.next.DeleteFolderAccess(ctx, folderId, request)
+	end(err)
+	return err
+}
+
+func (s instrumentedStorage) GetFolderAcl(ctx context.Context, folderId data.Id) (*data.FolderAcl, error) {
+	ctx, end := observe(ctx, "GetFolderAcl")
+	res, err := s.next.GetFolderAcl(ctx, folderId)
+	end(err)
+	return res, err
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"doccer/model"
+	"strconv"
+)
+
+func (p *PostgresStorage) GenerateNewOrgId(ctx context.Context) data.Id {
+	p.mu4.Lock()
+	defer p.mu4.Unlock()
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		p.log.ErrorContext(ctx, "generating id failed", "error", err)
+		return ""
+	}
+
+	row := tx.QueryRowContext(ctx, "select g.last_org_id from GeneralInfo g where g.base_id = 0")
+
+	lastId := 0
+	_ = row.Scan(&lastId)
+	id := strconv.Itoa(lastId)
+	lastId += 1
+
+	_, _ = tx.ExecContext(ctx, "update GeneralInfo set last_org_id = $1 where base_id = 0", lastId)
+
+	_ = tx.Commit()
+
+	return data.Id(id)
+}
+
+// CreateOrg stores the org together with its first owner.
+func (p *PostgresStorage) CreateOrg(ctx context.Context, org data.Org, ownerId data.Id) (*data.Org, error) {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "insert into Orgs values ($1, $2, $3)", org.Id, org.Name, org.DefaultAccess)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, model.ErrAlreadyExists
+	}
+	_, err = tx.ExecContext(ctx, "insert into OrgMember values ($1, $2, $3)", org.Id, ownerId, data.RoleOwner)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (p *PostgresStorage) GetOrg(ctx context.Context, orgId data.Id) (*data.Org, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select o.name, o.default_access from Orgs o where o.id = $1", orgId)
+	org := data.Org{Id: orgId}
+	err := res.Scan(&org.Name, &org.DefaultAccess)
+	if err != nil {
+		return nil, model.ErrNotFound
+	}
+	return &org, nil
+}
+
+func (p *PostgresStorage) EditOrg(ctx context.Context, org data.Org) (*data.Org, error) {
+	res, err := p.Dbc.ExecContext(ctx, "update Orgs set name = $1, default_access = $2 where id = $3", org.Name, org.DefaultAccess, org.Id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, model.ErrNotFound
+	}
+	return &org, nil
+}
+
+func (p *PostgresStorage) DeleteOrg(ctx context.Context, orgId data.Id) error {
+	res, err := p.Dbc.ExecContext(ctx, "delete from Orgs where id = $1", orgId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetUserOrgs(ctx context.Context, userId data.Id, page model.PageRequest) ([]data.Org, string, error) {
+	orgs := []data.Org{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"o.id"}}, "o.id, o.name, o.default_access",
+		"Orgs o join OrgMember m on m.org_id = o.id", []string{"m.member_id = $1"}, []interface{}{userId}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			org := data.Org{}
+			if err := res.Scan(append([]interface{}{&org.Id, &org.Name, &org.DefaultAccess}, keys...)...); err != nil {
+				return err
+			}
+			orgs = append(orgs, org)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return orgs, next, nil
+}
+
+func (p *PostgresStorage) GetOrgRole(ctx context.Context, orgId data.Id, userId data.Id) (data.Role, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select m.role from OrgMember m where m.org_id = $1 and m.member_id = $2", orgId, userId)
+	role := data.RoleMember
+	err := res.Scan(&role)
+	if err != nil {
+		return data.RoleMember, model.ErrNotFound
+	}
+	return role, nil
+}
+
+func (p *PostgresStorage) AddOrgMember(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	_, err := p.Dbc.ExecContext(ctx, "insert into OrgMember values ($1, $2, $3)", orgId, memberId, role)
+	if err != nil {
+		return model.ErrAlreadyExists
+	}
+	return nil
+}
+
+func (p *PostgresStorage) SetOrgMemberRole(ctx context.Context, orgId data.Id, memberId data.Id, role data.Role) error {
+	res, err := p.Dbc.ExecContext(ctx, "update OrgMember set role = $1 where org_id = $2 and member_id = $3", role, orgId, memberId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// RemoveOrgMember hands the org docs and folders the member created over to
+// an owner of the org, so that the member loses the access their
+// authorship gave.
+func (p *PostgresStorage) RemoveOrgMember(ctx context.Context, orgId data.Id, memberId data.Id) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, "delete from OrgMember where org_id = $1 and member_id = $2", orgId, memberId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	var ownerId data.Id
+	err = tx.QueryRowContext(ctx, "select member_id from OrgMember where org_id = $1 and role = $2 order by member_id limit 1",
+		orgId, data.RoleOwner).Scan(&ownerId)
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"Docs", "Folders"} {
+		_, err := tx.ExecContext(ctx, "update "+table+" set creator_id = $3 where org_id = $1 and creator_id = $2",
+			orgId, memberId, ownerId)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStorage) GetOrgMembers(ctx context.Context, orgId data.Id, page model.PageRequest) ([]data.Member, string, error) {
+	return p.queryMemberPage(ctx, "OrgMember m join Users u on u.id = m.member_id", "m.org_id = $1", orgId, page)
+}
+
+// GetOrgDocs lists the docs of the org that userId can read.
+func (p *PostgresStorage) GetOrgDocs(ctx context.Context, userId data.Id, orgId data.Id, page model.PageRequest) ([]data.Doc, string, error) {
+	return p.queryDocPage(ctx, docIdKeyset, "Docs d", []string{"d.org_id = $1", effectiveAccessExpr + " >= 1"},
+		[]interface{}{orgId, userId}, page)
+}
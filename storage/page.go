@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"doccer/model"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// keyset pages through rows ordered by keys, the last of which must be
+// unique. Cursors hold the keys of the last row of a page as text, so
+// the next page starts right after it however many rows were added or
+// removed before it in the meantime.
+type keyset struct {
+	keys []string
+	desc bool
+}
+
+// query builds "select columns, <keys> from from where conditions" for the
+// page, fetching one extra row to tell whether there is a next page.
+func (k keyset) query(columns string, from string, conditions []string, args []interface{},
+	page model.PageRequest) (string, []interface{}, error) {
+	keyColumns := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		keyColumns[i] = "(" + key + ")::text"
+	}
+
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, len(k.keys))
+		if err != nil {
+			return "", nil, err
+		}
+		params := make([]string, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			params[i] = "$" + strconv.Itoa(len(args))
+		}
+		op := " > "
+		if k.desc {
+			op = " < "
+		}
+		conditions = append(conditions, "("+strings.Join(k.keys, ", ")+")"+op+"("+strings.Join(params, ", ")+")")
+	}
+
+	order := strings.Join(k.keys, ", ")
+	if k.desc {
+		order = strings.Join(k.keys, " desc, ") + " desc"
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " where " + strings.Join(conditions, " and ")
+	}
+	args = append(args, page.Limit+1)
+	return "select " + columns + ", " + strings.Join(keyColumns, ", ") + " from " + from + where +
+		" order by " + order + " limit $" + strconv.Itoa(len(args)), args, nil
+}
+
+// queryPage runs a keyset query and calls scan for every row of the page.
+// scan gets the destinations of the row keys, which it has to append to its
+// own. It returns the cursor of the next page, or "" on the last one.
+func (p *PostgresStorage) queryPage(ctx context.Context, k keyset, columns string, from string, conditions []string, args []interface{},
+	page model.PageRequest, scan func(res *sql.Rows, keys ...interface{}) error) (string, error) {
+	query, args, err := k.query(columns, from, conditions, args, page)
+	if err != nil {
+		return "", err
+	}
+	res, err := p.Dbc.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	keys := make([]string, len(k.keys))
+	dest := make([]interface{}, len(keys))
+	for i := range keys {
+		dest[i] = &keys[i]
+	}
+	count := 0
+	for res.Next() {
+		if count == page.Limit {
+			return encodeCursor(keys), nil
+		}
+		if err := scan(res, dest...); err != nil {
+			return "", err
+		}
+		count++
+	}
+	return "", res.Err()
+}
+
+func encodeCursor(keys []string) string {
+	b, _ := json.Marshal(keys)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string, n int) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, model.ErrInvalidRequest
+	}
+	var keys []string
+	if err := json.Unmarshal(b, &keys); err != nil || len(keys) != n {
+		return nil, model.ErrInvalidRequest
+	}
+	return keys, nil
+}
+
+// docPageColumns are the doc columns of a listing: summaries leave the
+// text out.
+func docPageColumns(page model.PageRequest) string {
+	if page.Summary {
+		return strings.Replace(docColumns, "d.text", "''", 1)
+	}
+	return docColumns
+}
+
+// queryDocPage pages through the docs d of from that match conditions.
+func (p *PostgresStorage) queryDocPage(ctx context.Context, k keyset, from string, conditions []string, args []interface{},
+	page model.PageRequest) ([]data.Doc, string, error) {
+	docs := []data.Doc{}
+	next, err := p.queryPage(ctx, k, docPageColumns(page), from, conditions, args, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			doc, err := scanDoc(res, keys...)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, *doc)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return docs, next, nil
+}
+
+var docIdKeyset = keyset{keys: []string{"d.id"}}
@@ -3,31 +3,76 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"doccer/config"
 	"doccer/data"
 	"doccer/model"
-	_ "github.com/lib/pq"
+	"errors"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type PostgresStorage struct {
 	mu1 sync.Mutex
 	mu2 sync.Mutex
 	mu3 sync.Mutex
+	mu4 sync.Mutex
+	mu5 sync.Mutex
 	Dbc *sql.DB
+	log *slog.Logger
 }
 
-func (p *PostgresStorage) ClearAllTables() {
-	_, _ = p.Dbc.Exec("TRUNCATE Users, DocGroupRestriction, DocMemberRestriction, Docs, GroupMember, GeneralInfo, Groups1, Password CASCADE ;")
-	_, _ = p.Dbc.Exec("insert into GeneralInfo values (0, 0, 0, 0)")
+// NewPostgresStorage connects to the configured database. Queries made
+// within a trace get a span each.
+func NewPostgresStorage(cfg config.DatabaseConfig, log *slog.Logger) (*PostgresStorage, error) {
+	db, err := otelsql.Open("postgres", cfg.DSN(),
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return &PostgresStorage{Dbc: db, log: log}, nil
+}
+
+func (p *PostgresStorage) ClearAllTables(ctx context.Context) {
+	_, err := p.Dbc.ExecContext(ctx, "TRUNCATE Users, DocGroupRestriction, DocMemberRestriction, Docs, GroupMember, GroupSubgroup, GeneralInfo, Groups1, Orgs, OrgMember, "+
+		"Folders, FolderMemberRestriction, FolderGroupRestriction, DocSymbols, Password CASCADE ;")
+	if err == nil {
+		_, err = p.Dbc.ExecContext(ctx, "insert into GeneralInfo values (0, 0, 0, 0, 0, 0)")
+	}
+	if err != nil {
+		p.log.ErrorContext(ctx, "clearing tables failed", "error", err)
+	}
 }
 
-func (p *PostgresStorage) GenerateNewUserId() data.Id {
+func (p *PostgresStorage) GenerateNewUserId(ctx context.Context) data.Id {
 	p.mu1.Lock()
-	ctx := context.Background()
-	tx, _ := p.Dbc.BeginTx(ctx, nil)
+	defer p.mu1.Unlock()
+	// a cancelled ctx fails here; callers then fail to store the empty id
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		p.log.ErrorContext(ctx, "generating id failed", "error", err)
+		return ""
+	}
 
-	row := tx.QueryRow("select g.last_user_id from GeneralInfo g where g.base_id = 0")
+	row := tx.QueryRowContext(ctx, "select g.last_user_id from GeneralInfo g where g.base_id = 0")
 
 	lastId := 0
 	_ = row.Scan(&lastId)
@@ -38,16 +83,19 @@ func (p *PostgresStorage) GenerateNewUserId() data.Id {
 
 	_ = tx.Commit()
 
-	p.mu1.Unlock()
 	return data.Id(id)
 }
 
-func (p *PostgresStorage) GenerateNewDocId() data.Id {
+func (p *PostgresStorage) GenerateNewDocId(ctx context.Context) data.Id {
 	p.mu2.Lock()
-	ctx := context.Background()
-	tx, _ := p.Dbc.BeginTx(ctx, nil)
+	defer p.mu2.Unlock()
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		p.log.ErrorContext(ctx, "generating id failed", "error", err)
+		return ""
+	}
 
-	row := tx.QueryRow("select g.last_doc_id from GeneralInfo g where g.base_id = 0")
+	row := tx.QueryRowContext(ctx, "select g.last_doc_id from GeneralInfo g where g.base_id = 0")
 
 	lastId := 0
 	_ = row.Scan(&lastId)
@@ -58,16 +106,19 @@ func (p *PostgresStorage) GenerateNewDocId() data.Id {
 
 	_ = tx.Commit()
 
-	p.mu2.Unlock()
 	return data.Id(id)
 }
 
-func (p *PostgresStorage) GenerateNewGroupId() data.Id {
+func (p *PostgresStorage) GenerateNewGroupId(ctx context.Context) data.Id {
 	p.mu3.Lock()
-	ctx := context.Background()
-	tx, _ := p.Dbc.BeginTx(ctx, nil)
+	defer p.mu3.Unlock()
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		p.log.ErrorContext(ctx, "generating id failed", "error", err)
+		return ""
+	}
 
-	row := tx.QueryRow("select g.last_group_id from GeneralInfo g where g.base_id = 0")
+	row := tx.QueryRowContext(ctx, "select g.last_group_id from GeneralInfo g where g.base_id = 0")
 
 	lastId := 0
 	_ = row.Scan(&lastId)
@@ -78,12 +129,10 @@ func (p *PostgresStorage) GenerateNewGroupId() data.Id {
 
 	_ = tx.Commit()
 
-	p.mu3.Unlock()
 	return data.Id(id)
 }
 
-func (p *PostgresStorage) AddUser(newUser data.User, password model.Password) error {
-	ctx := context.Background()
+func (p *PostgresStorage) AddUser(ctx context.Context, newUser data.User, password model.Password) error {
 	tx, err := p.Dbc.BeginTx(ctx, nil);
 	if err != nil {
 		return err
@@ -107,8 +156,8 @@ func (p *PostgresStorage) AddUser(newUser data.User, password model.Password) er
 	return nil
 }
 
-func (p * PostgresStorage) GetUserByLogin(login string) (*data.User, error) {
-	res := p.Dbc.QueryRow("select u.id from Users u where u.login = $1", login)
+func (p * PostgresStorage) GetUserByLogin(ctx context.Context, login string) (*data.User, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select u.id from Users u where u.login = $1", login)
 	id := 0
 	err := res.Scan(&id)
 	if err != nil {
@@ -122,9 +171,9 @@ func (p * PostgresStorage) GetUserByLogin(login string) (*data.User, error) {
 	return &user, nil
 }
 
-func (p * PostgresStorage) GetUser(userId data.Id) (*data.User, error) {
+func (p * PostgresStorage) GetUser(ctx context.Context, userId data.Id) (*data.User, error) {
 	id, _ := strconv.Atoi(string(userId))
-	res := p.Dbc.QueryRow("select u.login from Users u where u.id = $1", id)
+	res := p.Dbc.QueryRowContext(ctx, "select u.login from Users u where u.id = $1", id)
 	login := ""
 	err := res.Scan(&login)
 	if err != nil {
@@ -138,13 +187,13 @@ func (p * PostgresStorage) GetUser(userId data.Id) (*data.User, error) {
 	return &user, nil
 }
 
-func (p * PostgresStorage) CheckLoginExists(login string) bool {
-	_, err := p.GetUserByLogin(login)
+func (p * PostgresStorage) CheckLoginExists(ctx context.Context, login string) bool {
+	_, err := p.GetUserByLogin(ctx, login)
 	return err == nil
 }
 
-func (p * PostgresStorage) GetHashedPassword(userId data.Id) (*model.Password, error) {
-	res := p.Dbc.QueryRow("select p.password from Password p where p.id = $1", userId)
+func (p * PostgresStorage) GetHashedPassword(ctx context.Context, userId data.Id) (*model.Password, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select p.password from Password p where p.id = $1", userId)
 	passwordStr := []byte("")
 	err := res.Scan(&passwordStr)
 	if err != nil {
@@ -154,114 +203,229 @@ func (p * PostgresStorage) GetHashedPassword(userId data.Id) (*model.Password, e
 	return &password, nil
 }
 
+func (p *PostgresStorage) SetPassword(ctx context.Context, userId data.Id, password model.Password) error {
+	_, err := p.Dbc.ExecContext(ctx, "update Password set password = $1 where id = $2", password, userId)
+	return err
+}
 
-func (p * PostgresStorage) EditUser(newUser data.User) (*data.User, error) {
-	_, err := p.Dbc.Exec("update Users u set u.login = $1 where u.id = $2", newUser.Id, newUser.Login)
+func (p * PostgresStorage) EditUser(ctx context.Context, newUser data.User) (*data.User, error) {
+	_, err := p.Dbc.ExecContext(ctx, "update Users u set u.login = $1 where u.id = $2", newUser.Id, newUser.Login)
 	if err != nil {
 		return nil, model.ErrNotFound
 	}
 	return &newUser, nil
 }
 
-func (p * PostgresStorage) CheckAccess(userId data.Id, docId data.Id) (string, error) {
-	doc, err := p.GetDoc(docId)
+// effectiveAccessExpr is the access of user $2 to the doc d: the strongest
+// grant returned by doc_access_grants (see initdb.sql).
+const effectiveAccessExpr = "(select max(g.access) from doc_access_grants(d.id, $2) g)"
+
+// Grants are ordered so that the first row is the rule that wins.
+const accessGrantsQuery = `
+select g.source, g.item_id, g.name, g.access from doc_access_grants($1, $2) g
+order by g.access desc,
+	case g.source when 'author' then 0 when 'org-admin' then 1 when 'folder-owner' then 2
+		when 'member' then 3 when 'folder-member' then 4 when 'group' then 5
+		when 'folder-group' then 6 when 'org' then 7 else 8 end,
+	g.item_id`
+
+func (p * PostgresStorage) CheckAccess(ctx context.Context, userId data.Id, docId data.Id) (data.AccessLevel, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select "+effectiveAccessExpr+" from Docs d where d.id = $1", docId, userId)
+	access := data.AccessNone
+	err := res.Scan(&access)
 	if err != nil {
-		return "none", err
+		return data.AccessNone, notFound(err)
 	}
+	return access, nil
+}
 
-	if userId == "-1" {
-		return doc.Access, nil
+func (p *PostgresStorage) GetAccessGrants(ctx context.Context, userId data.Id, docId data.Id) ([]data.AccessGrant, error) {
+	res, err := p.Dbc.QueryContext(ctx, accessGrantsQuery, docId, userId)
+	if err != nil {
+		return nil, err
 	}
+	defer res.Close()
 
-	if doc.AuthorId == userId {
-		return "absolute", nil
+	var grants []data.AccessGrant
+	for res.Next() {
+		grant := data.AccessGrant{}
+		name := sql.NullString{}
+		if err := res.Scan(&grant.Source, &grant.ItemId, &name, &grant.Access); err != nil {
+			return nil, err
+		}
+		grant.Name = name.String
+		grants = append(grants, grant)
 	}
+	if len(grants) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return grants, nil
+}
 
-	res := p.Dbc.QueryRow("select type from DocMemberRestriction where doc_id = $1 and member_id = $2", docId, userId)
-	userAccess := 0
-	err = res.Scan(&userAccess)
-	if err == nil {
-		return accessIntToStr(userAccess), nil
+// docColumns are the Docs columns read by scanDoc, in order.
+const docColumns = "d.id, d.creator_id, d.text, d.public_access_type, d.lang, d.lstatus, d.org_id, d.folder_id, " +
+	"d.title, d.description, " + docTagsExpr + ", d.created_at, d.updated_at, d.last_edited_by, d.version"
+
+const docTagsExpr = "coalesce((select array_agg(t.name order by t.name) from DocTags dt " +
+	"join Tags t on t.id = dt.tag_id where dt.doc_id = d.id), '{}')"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDoc(row rowScanner, extra ...interface{}) (*data.Doc, error) {
+	doc := data.Doc{}
+	dest := []interface{}{&doc.Id, &doc.AuthorId, &doc.Text, &doc.Access, &doc.Lang, &doc.LinterStatus, &doc.OrgId, &doc.FolderId,
+		&doc.Title, &doc.Description, pq.Array(&doc.Tags), &doc.CreatedAt, &doc.UpdatedAt, &doc.LastEditedBy, &doc.Version}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
 	}
+	return &doc, nil
+}
 
-	res2, err := p.Dbc.Query("select group_id, type from DocGroupRestriction where doc_id = $1", docId)
-	if err == nil {
-		userAccess = accessStrToInt(doc.Access)
-		for res2.Next() {
-			naccess := 0
-			groupId := ""
-			err = res2.Scan(&groupId, &naccess)
-			if err == nil {
-				members, err := p.GetMembers(model.GroupMembersChunkRequest{
-					Id:    data.Id(groupId),
-					Begin: 0,
-					Size:  0,
-				})
-
-				if err == nil {
-					contains := false
-					for _, member := range members {
-						if member.Id == userId {
-							contains = true
-						}
-					}
-
-					if contains && naccess > userAccess {
-						userAccess = naccess
-					}
-				}
-			}
-		}
-		return accessIntToStr(userAccess), nil
+// notFound maps a missing row to ErrNotFound and passes other errors on,
+// so that database failures are not mistaken for missing docs. Ids that are
+// not numbers name no row either.
+func notFound(err error) error {
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) && pqErr.Code == "22P02" {
+		return model.ErrNotFound
 	}
-	return doc.Access, nil
+	return err
 }
 
-func (p *PostgresStorage) GetDoc(docId data.Id) (*data.Doc, error) {
-	res := p.Dbc.QueryRow("select d.text, d.creator_id, d.public_access_type, d.lang, d.lstatus from Docs d where d.id = $1", docId)
-	text := ""
-	creatorId := ""
-	pubAccess := 0
-	lang := ""
-	lstatus := ""
-	err := res.Scan(&text, &creatorId, &pubAccess, &lang, &lstatus)
+// nullId maps the empty id to NULL for nullable reference columns.
+func nullId(id data.Id) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+func (p *PostgresStorage) GetDocWithAccess(ctx context.Context, userId data.Id, docId data.Id) (*data.Doc, data.AccessLevel, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select "+docColumns+", "+effectiveAccessExpr+" from Docs d where d.id = $1", docId, userId)
+	access := data.AccessNone
+	doc, err := scanDoc(res, &access)
+	if err != nil {
+		return nil, data.AccessNone, notFound(err)
+	}
+	return doc, access, nil
+}
+
+func (p *PostgresStorage) GetDoc(ctx context.Context, docId data.Id) (*data.Doc, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select "+docColumns+" from Docs d where d.id = $1", docId)
+	doc, err := scanDoc(res)
 	if err != nil {
 		return nil, model.ErrNotFound
 	}
-	return &data.Doc{
-		Id:           docId,
-		AuthorId:     data.Id(creatorId),
-		Text:         text,
-		Access:       accessIntToStr(pubAccess),
-		Lang:         lang,
-		LinterStatus: lstatus,
-	}, nil
+	return doc, nil
 }
 
-func (p * PostgresStorage) AddDoc(doc data.Doc) (*data.Id, error) {
-	_, err := p.Dbc.Exec("insert into Docs values ($1, $2, $3, $4, $5, $6)",
-		doc.Id, doc.AuthorId, doc.Text, accessStrToInt(doc.Access), doc.Lang, doc.LinterStatus)
+func (p * PostgresStorage) AddDoc(ctx context.Context, doc data.Doc) (*data.Id, error) {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
 	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "insert into Docs (id, creator_id, text, public_access_type, lang, lstatus, org_id, folder_id, "+
+		"title, description, created_at, updated_at, last_edited_by) "+
+		"values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+		doc.Id, doc.AuthorId, doc.Text, doc.Access, doc.Lang, doc.LinterStatus, nullId(doc.OrgId), nullId(doc.FolderId),
+		doc.Title, doc.Description, doc.CreatedAt, doc.UpdatedAt, nullId(doc.LastEditedBy))
+	if err != nil {
+		_ = tx.Rollback()
 		return nil, model.ErrAlreadyExists
 	}
+	err = setDocTags(ctx, tx, doc.Id, doc.Tags)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
 	return &doc.Id, nil
 }
 
-func (p * PostgresStorage) EditDoc(newDoc data.Doc) (*data.Doc, error) {
-	publicAccType := accessStrToInt(newDoc.Access)
-	_, _ = p.Dbc.Exec("update Docs set text = $1, public_access_type = $2, lang = $3, lstatus = $4 where id = $5",
-		newDoc.Text, publicAccType, newDoc.Lang, newDoc.LinterStatus, newDoc.Id)
-	return &newDoc, nil
+func (p * PostgresStorage) EditDoc(ctx context.Context, newDoc data.Doc) (*data.Doc, error) {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, "update Docs set text = $1, public_access_type = $2, lang = $3, lstatus = $4, "+
+		"title = $5, description = $6, updated_at = $7, last_edited_by = $8, version = version + 1 "+
+		"where id = $9 and ($10::int = 0 or version = $10::int)",
+		newDoc.Text, newDoc.Access, newDoc.Lang, newDoc.LinterStatus,
+		newDoc.Title, newDoc.Description, newDoc.UpdatedAt, nullId(newDoc.LastEditedBy), newDoc.Id, newDoc.Version)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		if _, err := p.GetDoc(ctx, newDoc.Id); err == nil && newDoc.Version != 0 {
+			return nil, model.ErrVersionConflict
+		}
+		return nil, model.ErrNotFound
+	}
+	err = setDocTags(ctx, tx, newDoc.Id, newDoc.Tags)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetDoc(ctx, newDoc.Id)
+}
+
+// setDocTags replaces the tags of the doc, creating missing ones.
+func setDocTags(ctx context.Context, tx *sql.Tx, docId data.Id, tags []string) error {
+	_, err := tx.ExecContext(ctx, "delete from DocTags where doc_id = $1", docId)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "insert into Tags (name) select unnest($1::text[]) on conflict (name) do nothing", pq.Array(tags))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "insert into DocTags select $1, t.id from Tags t where t.name = any($2::text[])", docId, pq.Array(tags))
+	return err
+}
+
+// SetLinterStatus stores a lint result of the given version of a doc
+// without touching the rest of it. Results of older versions are dropped,
+// so it never overwrites edits made while the linter was running.
+func (p *PostgresStorage) SetLinterStatus(ctx context.Context, docId data.Id, version int, status string) error {
+	_, err := p.Dbc.ExecContext(ctx, "update Docs set lstatus = $1 where id = $2 and version = $3", status, docId, version)
+	return err
+}
+
+func (p *PostgresStorage) GetUserUsage(ctx context.Context, userId data.Id) (*data.Usage, error) {
+	var usage data.Usage
+	err := p.Dbc.QueryRowContext(ctx, "select count(*), coalesce(sum(octet_length(text)), 0) from Docs where creator_id = $1", userId).
+		Scan(&usage.Docs, &usage.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
 }
 
-func (p * PostgresStorage) EditDocAccess(docId data.Id, editRequest model.DocAccessRequest) error {
-	if editRequest.Type == 0 {
-		_, err := p.Dbc.Exec("insert into DocMemberRestriction values ($1, $2, $3) on conflict(doc_id, member_id) do update set type = excluded.type;", docId, editRequest.ItemId, accessStrToInt(editRequest.Access))
+func (p * PostgresStorage) EditDocAccess(ctx context.Context, docId data.Id, editRequest model.DocAccessRequest) error {
+	if editRequest.Type == model.MemberAccess {
+		_, err := p.Dbc.ExecContext(ctx, "insert into DocMemberRestriction values ($1, $2, $3) on conflict(doc_id, member_id) do update set type = excluded.type;", docId, editRequest.ItemId, editRequest.Access)
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err := p.Dbc.Exec("insert into DocGroupRestriction values ($1, $2, $3) on conflict(doc_id, group_id) do update set type = excluded.type", docId, editRequest.ItemId, accessStrToInt(editRequest.Access))
+		_, err := p.Dbc.ExecContext(ctx, "insert into DocGroupRestriction values ($1, $2, $3) on conflict(doc_id, group_id) do update set type = excluded.type", docId, editRequest.ItemId, editRequest.Access)
 		if err != nil {
 			return err
 		}
@@ -269,86 +433,206 @@ func (p * PostgresStorage) EditDocAccess(docId data.Id, editRequest model.DocAcc
 	return nil
 }
 
-func (p * PostgresStorage) GetAllDocs(userId data.Id) ([]data.Doc, error) {
-	res, err := p.Dbc.Query("select d.id, d.text, d.access, d.lang, d.lstatus from Docs d where d.creator_id = $1", userId)
+func (p *PostgresStorage) DeleteDocAccess(ctx context.Context, docId data.Id, request model.DocAccessRequest) error {
+	query := "delete from DocMemberRestriction where doc_id = $1 and member_id = $2"
+	if request.Type == model.GroupAccess {
+		query = "delete from DocGroupRestriction where doc_id = $1 and group_id = $2"
+	}
+	res, err := p.Dbc.ExecContext(ctx, query, docId, request.ItemId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStorage) GetDocAcl(ctx context.Context, docId data.Id) (*data.DocAcl, error) {
+	acl := data.DocAcl{
+		DocId:   docId,
+		Members: []data.MemberGrant{},
+		Groups:  []data.GroupGrant{},
+	}
+	err := p.Dbc.QueryRowContext(ctx, "select d.public_access_type from Docs d where d.id = $1", docId).Scan(&acl.Public)
+	if err != nil {
+		return nil, model.ErrNotFound
+	}
+
+	members, err := p.Dbc.QueryContext(ctx, "select m.member_id, u.login, m.type from DocMemberRestriction m "+
+		"join Users u on u.id = m.member_id where m.doc_id = $1 order by m.member_id", docId)
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+	for members.Next() {
+		grant := data.MemberGrant{}
+		if err := members.Scan(&grant.UserId, &grant.Login, &grant.Access); err != nil {
+			return nil, err
+		}
+		acl.Members = append(acl.Members, grant)
+	}
+	if err := members.Err(); err != nil {
+		return nil, err
+	}
+
+	groups, err := p.Dbc.QueryContext(ctx, "select r.group_id, g.name, r.type from DocGroupRestriction r "+
+		"join Groups1 g on g.id = r.group_id where r.doc_id = $1 order by r.group_id", docId)
 	if err != nil {
 		return nil, err
 	}
-	var docs []data.Doc
+	defer groups.Close()
+	for groups.Next() {
+		grant := data.GroupGrant{}
+		if err := groups.Scan(&grant.GroupId, &grant.Name, &grant.Access); err != nil {
+			return nil, err
+		}
+		acl.Groups = append(acl.Groups, grant)
+	}
+	if err := groups.Err(); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
 
-	for res.Next() {
-		text := ""
-		id := ""
-		access := 0
-		lang := ""
-		lstatus := ""
-		_ = res.Scan(&id, &text, &access, &lang, &lstatus)
-
-		accessStr := accessIntToStr(access)
-		docs = append(docs, data.Doc{
-			Id:           data.Id(id),
-			AuthorId:     userId,
-			Text:         text,
-			Access:       accessStr,
-			Lang:         lang,
-			LinterStatus: lstatus,
-		})
+func (p *PostgresStorage) ReplaceDocAcl(ctx context.Context, acl data.DocAcl) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "update Docs set public_access_type = $1 where id = $2", acl.Public, acl.DocId)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return model.ErrNotFound
+	}
+
+	statements := []string{
+		"delete from DocMemberRestriction where doc_id = $1",
+		"delete from DocGroupRestriction where doc_id = $1",
+	}
+	for _, statement := range statements {
+		if _, err = tx.ExecContext(ctx, statement, acl.DocId); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	for _, grant := range acl.Members {
+		_, err = tx.ExecContext(ctx, "insert into DocMemberRestriction values ($1, $2, $3)", acl.DocId, grant.UserId, grant.Access)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	for _, grant := range acl.Groups {
+		_, err = tx.ExecContext(ctx, "insert into DocGroupRestriction values ($1, $2, $3)", acl.DocId, grant.GroupId, grant.Access)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
 	}
-	return docs, nil
+	return tx.Commit()
 }
 
-func (p * PostgresStorage) DeleteDoc(docId data.Id) error {
-	_, err := p.Dbc.Exec("delete from Docs d where d.id = $1", docId)
+var docSortColumns = map[string]string{
+	"created_at": "d.created_at",
+	"updated_at": "d.updated_at",
+	"title":      "d.title",
+}
+
+func (p * PostgresStorage) GetAllDocs(ctx context.Context, userId data.Id, filter model.DocFilter, page model.PageRequest) ([]data.Doc, string, error) {
+	conditions := []string{"d.creator_id = $1"}
+	args := []interface{}{userId}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, "exists (select 1 from DocTags dt join Tags t on t.id = dt.tag_id "+
+			"where dt.doc_id = d.id and t.name = $"+strconv.Itoa(len(args))+")")
+	}
+	if filter.Lang != "" {
+		args = append(args, filter.Lang)
+		conditions = append(conditions, "d.lang = $"+strconv.Itoa(len(args)))
+	}
+	if filter.Title != "" {
+		args = append(args, filter.Title)
+		conditions = append(conditions, "strpos(lower(d.title), lower($"+strconv.Itoa(len(args))+")) > 0")
+	}
+	for _, bound := range []struct {
+		condition string
+		value     time.Time
+	}{
+		{"d.created_at > $", filter.CreatedAfter},
+		{"d.created_at < $", filter.CreatedBefore},
+		{"d.updated_at > $", filter.UpdatedAfter},
+		{"d.updated_at < $", filter.UpdatedBefore},
+	} {
+		if !bound.value.IsZero() {
+			args = append(args, bound.value)
+			conditions = append(conditions, bound.condition+strconv.Itoa(len(args)))
+		}
+	}
+	if filter.EditedBy != "" {
+		args = append(args, filter.EditedBy)
+		conditions = append(conditions, "d.last_edited_by = $"+strconv.Itoa(len(args)))
+	}
+
+	order := keyset{keys: []string{"d.id"}, desc: filter.Desc}
+	if filter.Sort != "" {
+		order.keys = []string{docSortColumns[filter.Sort], "d.id"}
+	}
+	return p.queryDocPage(ctx, order, "Docs d", conditions, args, page)
+}
+
+func (p * PostgresStorage) DeleteDoc(ctx context.Context, docId data.Id) error {
+	_, err := p.Dbc.ExecContext(ctx, "delete from Docs d where d.id = $1", docId)
 	if err != nil {
 		return model.ErrNotFound
 	}
 	return nil
 }
 
-func (p * PostgresStorage) CreateGroup(group data.Group) (*data.Group, error) {
-	_, err := p.Dbc.Exec("insert into Groups1 values ($1, $2, $3)", group.Id, group.Creator, group.Name)
+func (p * PostgresStorage) CreateGroup(ctx context.Context, group data.Group) (*data.Group, error) {
+	_, err := p.Dbc.ExecContext(ctx, "insert into Groups1 (id, creator_id, name, org_id) values ($1, $2, $3, $4)",
+		group.Id, group.Creator, group.Name, nullId(group.OrgId))
 	if err != nil {
 		return nil, err
 	}
 	return &group, nil
 }
 
-func (p * PostgresStorage) DeleteGroup(groupId data.Id) error {
-	_, err := p.Dbc.Exec("delete from Groups1 g where g.id = $1", groupId)
+func (p * PostgresStorage) DeleteGroup(ctx context.Context, groupId data.Id) error {
+	_, err := p.Dbc.ExecContext(ctx, "delete from Groups1 g where g.id = $1", groupId)
 	if err != nil {
 		return model.ErrNotFound
 	}
 	return nil
 }
 
-func (p * PostgresStorage) EditGroup(newGroup data.Group) (*data.Group, error) {
-	_, err := p.Dbc.Exec("update Groups1 set name = $1 where id = $2", newGroup.Name, newGroup.Id)
+func (p * PostgresStorage) EditGroup(ctx context.Context, newGroup data.Group) (*data.Group, error) {
+	_, err := p.Dbc.ExecContext(ctx, "update Groups1 set name = $1 where id = $2", newGroup.Name, newGroup.Id)
 	if err != nil {
 		return nil, err
 	}
 	return &newGroup, nil
 }
 
-func (p * PostgresStorage) GetGroupById(groupId data.Id) (*data.Group, error) {
-	res := p.Dbc.QueryRow("select g.name, g.creator_id from Groups1 g where g.id = $1", groupId)
-	name := ""
-	creatorId := ""
-	err := res.Scan(&name, &creatorId)
+func (p * PostgresStorage) GetGroupById(ctx context.Context, groupId data.Id) (*data.Group, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select g.name, g.creator_id, g.org_id from Groups1 g where g.id = $1", groupId)
+	group := data.Group{Id: groupId}
+	err := res.Scan(&group.Name, &group.Creator, &group.OrgId)
 
 	if err != nil {
 		return nil, model.ErrNotFound
 	}
-
-	group := data.Group{
-		Id:      groupId,
-		Name:    name,
-		Creator: data.Id(creatorId),
-	}
 	return &group, nil
 }
 
-func (p * PostgresStorage) AddMember(groupId data.Id, newMemberId data.Id) error {
-	_, err := p.Dbc.Exec("insert into GroupMember values ($1, $2)", groupId, newMemberId)
+func (p * PostgresStorage) AddMember(ctx context.Context, groupId data.Id, newMemberId data.Id) error {
+	_, err := p.Dbc.ExecContext(ctx, "insert into GroupMember values ($1, $2)", groupId, newMemberId)
 	if err != nil {
 		return model.ErrAlreadyExists
 	}
@@ -356,8 +640,8 @@ func (p * PostgresStorage) AddMember(groupId data.Id, newMemberId data.Id) error
 	return nil
 }
 
-func (p * PostgresStorage) RemoveMember(groupId data.Id, memberId data.Id) error {
-	_, err := p.Dbc.Exec("delete from GroupMember g where g.group_id = $1 and g.member_id = $2", groupId, memberId)
+func (p * PostgresStorage) RemoveMember(ctx context.Context, groupId data.Id, memberId data.Id) error {
+	_, err := p.Dbc.ExecContext(ctx, "delete from GroupMember g where g.group_id = $1 and g.member_id = $2", groupId, memberId)
 	if err != nil {
 		return model.ErrNotFound
 	}
@@ -365,46 +649,153 @@ func (p * PostgresStorage) RemoveMember(groupId data.Id, memberId data.Id) error
 	return nil
 }
 
-func (p * PostgresStorage) GetMembers(request model.GroupMembersChunkRequest) ([]data.User, error) {
-	res, err := p.Dbc.Query("select g.member_id from GroupMember g where g.group_id = $1", request.Id)
+func (p * PostgresStorage) GetMembers(ctx context.Context, groupId data.Id, page model.PageRequest) ([]data.Member, string, error) {
+	return p.queryMemberPage(ctx, "GroupMember m join Users u on u.id = m.member_id", "m.group_id = $1", groupId, page)
+}
+
+// queryMemberPage pages through the members m of from, ordered by login.
+func (p *PostgresStorage) queryMemberPage(ctx context.Context, from string, condition string, id data.Id,
+	page model.PageRequest) ([]data.Member, string, error) {
+	members := []data.Member{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"u.login", "u.id"}}, "u.id, u.login, m.role", from,
+		[]string{condition}, []interface{}{id}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			member := data.Member{}
+			if err := res.Scan(append([]interface{}{&member.Id, &member.Login, &member.Role}, keys...)...); err != nil {
+				return err
+			}
+			members = append(members, member)
+			return nil
+		})
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return members, next, nil
+}
+
+func (p *PostgresStorage) GetMemberRole(ctx context.Context, groupId data.Id, userId data.Id) (data.Role, error) {
+	res := p.Dbc.QueryRowContext(ctx, "select case when g.creator_id = $2 then 2 else (select m.role from GroupMember m "+
+		"where m.group_id = g.id and m.member_id = $2) end from Groups1 g where g.id = $1", groupId, userId)
+	role := sql.NullInt64{}
+	err := res.Scan(&role)
+	if err != nil || !role.Valid {
+		return data.RoleMember, model.ErrNotFound
 	}
-	var users []data.User
+	return data.Role(role.Int64), nil
+}
 
-	for res.Next() {
-		user_id := ""
-		_ = res.Scan(&user_id)
-		user, _ := p.GetUser(data.Id(user_id))
-		users = append(users, *user)
+func (p *PostgresStorage) SetMemberRole(ctx context.Context, groupId data.Id, memberId data.Id, role data.Role) error {
+	res, err := p.Dbc.ExecContext(ctx, "update GroupMember set role = $1 where group_id = $2 and member_id = $3", role, groupId, memberId)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// TransferGroupOwnership makes a member the group creator; the previous
+// creator stays in the group as an admin.
+func (p *PostgresStorage) TransferGroupOwnership(ctx context.Context, groupId data.Id, newOwnerId data.Id) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	oldOwnerId := ""
+	err = tx.QueryRowContext(ctx, "select creator_id from Groups1 where id = $1 for update", groupId).Scan(&oldOwnerId)
+	if err != nil {
+		_ = tx.Rollback()
+		return model.ErrNotFound
+	}
+	// the row stays, as doc_access_grants finds the groups of a user through it
+	res, err := tx.ExecContext(ctx, "update GroupMember set role = $3 where group_id = $1 and member_id = $2",
+		groupId, newOwnerId, data.RoleOwner)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		_ = tx.Rollback()
+		return model.ErrNotFound
+	}
+	_, err = tx.ExecContext(ctx, "update Groups1 set creator_id = $1 where id = $2", newOwnerId, groupId)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	// the old owner may already have a member row, e.g. from an org admin
+	_, err = tx.ExecContext(ctx, "insert into GroupMember values ($1, $2, $3) "+
+		"on conflict (group_id, member_id) do update set role = excluded.role", groupId, oldOwnerId, data.RoleAdmin)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
 	}
-	return users, nil
+	return tx.Commit()
 }
 
-func accessStrToInt(accessStr string) int {
-	accessCode := 1 //read
-	if accessStr == "absolute" {
-		accessCode = 3
+// AddSubgroup nests subgroupId into groupId, failing with ErrGroupCycle if
+// groupId is already nested in subgroupId. The table lock keeps two
+// concurrent nestings from closing a cycle between the check and the insert.
+func (p *PostgresStorage) AddSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
-	if accessStr == "edit" {
-		accessCode = 2
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "lock table GroupSubgroup in share row exclusive mode"); err != nil {
+		return err
 	}
-	if accessStr == "none" {
-		accessCode = 0
+	cycle := false
+	if err := tx.QueryRowContext(ctx, groupContainsQuery, subgroupId, groupId).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return model.ErrGroupCycle
 	}
-	return accessCode
+	if _, err := tx.ExecContext(ctx, "insert into GroupSubgroup values ($1, $2)", groupId, subgroupId); err != nil {
+		return model.ErrAlreadyExists
+	}
+	return tx.Commit()
 }
 
-func accessIntToStr(accessCode int) string {
-	accessStr := "read"
-	if accessCode == 3 {
-		accessStr = "absolute"
+func (p *PostgresStorage) RemoveSubgroup(ctx context.Context, groupId data.Id, subgroupId data.Id) error {
+	res, err := p.Dbc.ExecContext(ctx, "delete from GroupSubgroup where group_id = $1 and subgroup_id = $2", groupId, subgroupId)
+	if err != nil {
+		return err
 	}
-	if accessCode == 1 {
-		accessStr = "edit"
+	if n, _ := res.RowsAffected(); n == 0 {
+		return model.ErrNotFound
 	}
-	if accessCode == 0 {
-		accessStr = "none"
+	return nil
+}
+
+func (p *PostgresStorage) GetSubgroups(ctx context.Context, groupId data.Id, page model.PageRequest) ([]data.Group, string, error) {
+	groups := []data.Group{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"g.id"}}, "g.id, g.name, g.creator_id, g.org_id",
+		"GroupSubgroup s join Groups1 g on g.id = s.subgroup_id", []string{"s.group_id = $1"}, []interface{}{groupId}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			group := data.Group{}
+			if err := res.Scan(append([]interface{}{&group.Id, &group.Name, &group.Creator, &group.OrgId}, keys...)...); err != nil {
+				return err
+			}
+			groups = append(groups, group)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
 	}
-	return accessStr
-}
\ No newline at end of file
+	return groups, next, nil
+}
+
+// groupContainsQuery tells whether group $2 is nested in group $1 at any
+// depth.
+const groupContainsQuery = `
+with recursive descendants(id) as (
+	select s.subgroup_id from GroupSubgroup s where s.group_id = $1
+	union
+	select s.subgroup_id from GroupSubgroup s join descendants d on s.group_id = d.id
+)
+select exists(select 1 from descendants where id = $2)`
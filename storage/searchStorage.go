@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"doccer/model"
+	"errors"
+	"github.com/lib/pq"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetContext is the number of bytes of text kept around a match.
+const snippetContext = 60
+
+// ts_headline marks matches with these private-use characters, removed from
+// the text beforehand, so that the snippet can be escaped before the marks
+// are turned into <mark> tags.
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+var headlineMarks = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>")
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchDocs runs the search over the docs userId can read. Full-text queries
+// use the search_vector column, substring and regex queries the trigram index
+// on the text.
+func (p *PostgresStorage) SearchDocs(ctx context.Context, userId data.Id, request model.SearchRequest, page model.PageRequest) ([]data.SearchResult, string, error) {
+	rank := "0::real"
+	// substring and regex snippets are cut from the text in Go, regex ones
+	// around the character positions of the match Postgres found
+	snippet := "d.text"
+	matchPos := "0, 0"
+	var match string
+	args := []interface{}{request.Query, userId}
+	switch request.Mode {
+	case model.SearchSubstring:
+		args[0] = "%" + likeEscaper.Replace(request.Query) + "%"
+		match = "d.text like $1"
+	case model.SearchRegex:
+		match = "d.text ~ $1"
+		matchPos = "regexp_instr(d.text, $1), regexp_instr(d.text, $1, 1, 1, 1)"
+	default:
+		match = "d.search_vector @@ websearch_to_tsquery('simple', $1)"
+		rank = "ts_rank(d.search_vector, websearch_to_tsquery('simple', $1))"
+		snippet = "ts_headline('simple', translate(coalesce(d.text, ''), '" + headlineStart + headlineStop + "', ''), " +
+			"websearch_to_tsquery('simple', $1), " +
+			"'StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MaxFragments=3')"
+	}
+
+	conditions := []string{match, effectiveAccessExpr + " >= 1"}
+	if request.Lang != "" {
+		args = append(args, request.Lang)
+		conditions = append(conditions, "d.lang = $"+strconv.Itoa(len(args)))
+	}
+	if request.Owner != "" {
+		args = append(args, request.Owner)
+		conditions = append(conditions, "d.creator_id = $"+strconv.Itoa(len(args)))
+	}
+	if request.Tag != "" {
+		args = append(args, request.Tag)
+		conditions = append(conditions, "exists (select 1 from DocTags dt join Tags t on t.id = dt.tag_id "+
+			"where dt.doc_id = d.id and t.name = $"+strconv.Itoa(len(args))+")")
+	}
+
+	results := []data.SearchResult{}
+	order := keyset{keys: []string{rank, "d.id"}, desc: true}
+	next, err := p.queryPage(ctx, order, docPageColumns(page)+", "+rank+", "+snippet+", "+matchPos, "Docs d", conditions, args, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			result := data.SearchResult{}
+			start, end := 0, 0
+			doc, err := scanDoc(res, append([]interface{}{&result.Rank, &result.Snippet, &start, &end}, keys...)...)
+			if err != nil {
+				return err
+			}
+			result.Doc = *doc
+
+			text := result.Snippet
+			switch request.Mode {
+			case model.SearchSubstring:
+				result.Snippet = ""
+				if i := strings.Index(text, request.Query); i >= 0 {
+					result.Snippet = highlight(text, i, i+len(request.Query))
+				}
+			case model.SearchRegex:
+				result.Snippet = ""
+				// regexp_instr counts characters from 1 and ends past the match
+				if start > 0 && end >= start {
+					from := runeOffset(text, start-1)
+					result.Snippet = highlight(text, from, from+runeOffset(text[from:], end-start))
+				}
+			default:
+				result.Snippet = headlineMarks.Replace(html.EscapeString(text))
+			}
+			results = append(results, result)
+			return nil
+		})
+	if err != nil {
+		return nil, "", queryError(err)
+	}
+	return results, next, nil
+}
+
+// queryError turns the error Postgres reports for regexes it rejects into
+// an invalid request. Other errors, including syntax errors of the SQL we
+// generate, are passed on.
+func queryError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "2201B" {
+		return &model.Error{Code: model.ErrInvalidRequest.Code, Message: "invalid query: " + pqErr.Message}
+	}
+	return err
+}
+
+// runeOffset returns the byte offset of the n-th character of text, or
+// len(text) if it is shorter.
+func runeOffset(text string, n int) int {
+	for i := range text {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(text)
+}
+
+// highlight cuts the text around text[start:end], escapes it as HTML and
+// marks the match.
+func highlight(text string, start int, end int) string {
+	from := start - snippetContext
+	if from < 0 {
+		from = 0
+	}
+	to := end + snippetContext
+	if to > len(text) {
+		to = len(text)
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return html.EscapeString(text[from:start]) + "<mark>" + html.EscapeString(text[start:end]) + "</mark>" +
+		html.EscapeString(text[end:to])
+}
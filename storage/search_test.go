@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"doccer/model"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"html"
+	"testing"
+)
+
+func TestQueryError(t *testing.T) {
+	lost := errors.New("driver: bad connection")
+	tests := []struct {
+		err         error
+		wantInvalid bool
+	}{
+		{&pq.Error{Code: "2201B", Message: "invalid regular expression"}, true},
+		{fmt.Errorf("query: %w", &pq.Error{Code: "2201B", Message: "invalid regular expression"}), true},
+		{&pq.Error{Code: "42601", Message: "syntax error"}, false},
+		{&pq.Error{Code: "57014", Message: "canceling statement due to user request"}, false},
+		{context.Canceled, false},
+		{lost, false},
+		{model.ErrInvalidRequest, true},
+	}
+	for _, tt := range tests {
+		got := queryError(tt.err)
+		if errors.Is(got, model.ErrInvalidRequest) != tt.wantInvalid {
+			t.Errorf("queryError(%v) = %v, want invalid request %t", tt.err, got, tt.wantInvalid)
+		}
+		if !tt.wantInvalid && got != tt.err {
+			t.Errorf("queryError(%v) = %v, want it unchanged", tt.err, got)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		want       string
+	}{
+		{"a <b> c", 2, 5, "a <mark>&lt;b&gt;</mark> c"},
+		{"<script>x</script>", 8, 9, "&lt;script&gt;<mark>x</mark>&lt;/script&gt;"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.start, tt.end); got != tt.want {
+			t.Errorf("highlight(%q, %d, %d) = %q, want %q", tt.text, tt.start, tt.end, got, tt.want)
+		}
+	}
+	if got := headlineMarks.Replace(html.EscapeString("x \uE000<y>\uE001")); got != "x <mark>&lt;y&gt;</mark>" {
+		t.Errorf("headline = %q", got)
+	}
+}
+
+func TestRuneOffset(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want int
+	}{
+		{"abc", 1, 1},
+		{"äbc", 1, 2},
+		{"äbc", 3, 4},
+		{"äbc", 10, 4},
+	}
+	for _, tt := range tests {
+		if got := runeOffset(tt.text, tt.n); got != tt.want {
+			t.Errorf("runeOffset(%q, %d) = %d, want %d", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"doccer/data"
+	"doccer/model"
+	"errors"
+)
+
+// ReplaceDocSymbols swaps the indexed symbols of a doc for those extracted
+// from the given version of its text. Symbols of a version that was edited
+// since are dropped; the row lock orders concurrent replacements.
+func (p *PostgresStorage) ReplaceDocSymbols(ctx context.Context, docId data.Id, version int, symbols []data.Symbol) error {
+	tx, err := p.Dbc.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current := 0
+	err = tx.QueryRowContext(ctx, "select version from Docs where id = $1 for update", docId).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "delete from DocSymbols where doc_id = $1", docId); err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		_, err := tx.ExecContext(ctx, "insert into DocSymbols(doc_id, name, kind, receiver, line) values ($1, $2, $3, $4, $5)",
+			docId, symbol.Name, symbol.Kind, symbol.Receiver, symbol.Line)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDocSymbols lists a page of the symbols of a doc in source order.
+func (p *PostgresStorage) GetDocSymbols(ctx context.Context, docId data.Id, page model.PageRequest) ([]data.Symbol, string, error) {
+	symbols := []data.Symbol{}
+	next, err := p.queryPage(ctx, keyset{keys: []string{"s.line", "s.name", "s.id"}}, "s.doc_id, s.name, s.kind, s.receiver, s.line",
+		"DocSymbols s", []string{"s.doc_id = $1"}, []interface{}{docId}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			symbol, err := scanSymbol(res, keys...)
+			if err != nil {
+				return err
+			}
+			symbols = append(symbols, *symbol)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return symbols, next, nil
+}
+
+// FindSymbols looks up symbols whose name starts with query, ignoring case,
+// in the docs userId can read. Exact matches come first.
+func (p *PostgresStorage) FindSymbols(ctx context.Context, userId data.Id, query string, kind string, page model.PageRequest) ([]data.Symbol, string, error) {
+	symbols := []data.Symbol{}
+	order := keyset{keys: []string{"lower(s.name) <> lower($4::text)", "lower(s.name)", "s.id"}}
+	next, err := p.queryPage(ctx, order, "s.doc_id, s.name, s.kind, s.receiver, s.line",
+		"DocSymbols s join Docs d on d.id = s.doc_id",
+		[]string{"lower(s.name) like lower($1::text)", "($3::text = '' or s.kind = $3)", effectiveAccessExpr + " >= 1"},
+		[]interface{}{likeEscaper.Replace(query) + "%", userId, kind, query}, page,
+		func(res *sql.Rows, keys ...interface{}) error {
+			symbol, err := scanSymbol(res, keys...)
+			if err != nil {
+				return err
+			}
+			symbols = append(symbols, *symbol)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return symbols, next, nil
+}
+
+func scanSymbol(row rowScanner, extra ...interface{}) (*data.Symbol, error) {
+	symbol := data.Symbol{}
+	dest := []interface{}{&symbol.DocId, &symbol.Name, &symbol.Kind, &symbol.Receiver, &symbol.Line}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &symbol, nil
+}
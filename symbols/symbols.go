@@ -0,0 +1,94 @@
+package symbols
+
+import (
+	"doccer/data"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+const (
+	KindImport = "import"
+	KindFunc   = "func"
+	KindMethod = "method"
+	KindType   = "type"
+)
+
+// Extract returns the imports, functions, methods and types declared in
+// a Go doc. Snippets without a package clause are parsed as if they had one,
+// and declarations are still collected from code with syntax errors.
+func Extract(doc data.Doc) []data.Symbol {
+	if doc.Lang != "go" {
+		return nil
+	}
+	fset := token.NewFileSet()
+	lineOffset := 0
+	file, _ := parser.ParseFile(fset, "", doc.Text, parser.SkipObjectResolution)
+	if file == nil || file.Name == nil || file.Name.Name == "" {
+		// the added package clause shifts every line by one
+		lineOffset = 1
+		file, _ = parser.ParseFile(fset, "", "package snippet\n"+doc.Text, parser.SkipObjectResolution)
+	}
+	if file == nil {
+		return nil
+	}
+
+	var res []data.Symbol
+	add := func(name string, kind string, receiver string, pos token.Pos) {
+		if name == "" || name == "_" {
+			return
+		}
+		res = append(res, data.Symbol{
+			DocId:    doc.Id,
+			Name:     name,
+			Kind:     kind,
+			Receiver: receiver,
+			Line:     fset.Position(pos).Line - lineOffset,
+		})
+	}
+
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		add(path, KindImport, "", spec.Pos())
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				add(decl.Name.Name, KindMethod, receiverName(decl.Recv.List[0].Type), decl.Name.Pos())
+			} else {
+				add(decl.Name.Name, KindFunc, "", decl.Name.Pos())
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+					add(typeSpec.Name.Name, KindType, "", typeSpec.Name.Pos())
+				}
+			}
+		}
+	}
+	return res
+}
+
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.ParenExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
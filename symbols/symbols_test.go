@@ -0,0 +1,94 @@
+package symbols
+
+import (
+	"doccer/data"
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		lang string
+		text string
+		want []data.Symbol
+	}{
+		{
+			name: "file",
+			lang: "go",
+			text: `package main
+
+import (
+	"fmt"
+	_ "embed"
+)
+
+type Server struct{}
+
+type (
+	Handler func()
+	Pair[K comparable, V any] struct{}
+)
+
+func main() {}
+
+func (s *Server) Serve() {}
+
+func (p Pair[K, V]) Key() {}
+
+func _() {}
+`,
+			want: []data.Symbol{
+				{Name: "fmt", Kind: KindImport, Line: 4},
+				{Name: "embed", Kind: KindImport, Line: 5},
+				{Name: "Server", Kind: KindType, Line: 8},
+				{Name: "Handler", Kind: KindType, Line: 11},
+				{Name: "Pair", Kind: KindType, Line: 12},
+				{Name: "main", Kind: KindFunc, Line: 15},
+				{Name: "Serve", Kind: KindMethod, Receiver: "Server", Line: 17},
+				{Name: "Key", Kind: KindMethod, Receiver: "Pair", Line: 19},
+			},
+		},
+		{
+			name: "snippet without package clause",
+			lang: "go",
+			text: "import \"os\"\n\nfunc run() {}\n",
+			want: []data.Symbol{
+				{Name: "os", Kind: KindImport, Line: 1},
+				{Name: "run", Kind: KindFunc, Line: 3},
+			},
+		},
+		{
+			name: "syntax error",
+			lang: "go",
+			text: "package p\n\nfunc ok() {}\n\nfunc broken( {\n",
+			want: []data.Symbol{
+				{Name: "ok", Kind: KindFunc, Line: 3},
+				{Name: "broken", Kind: KindFunc, Line: 5},
+			},
+		},
+		{
+			name: "not go",
+			lang: "Text",
+			text: "package p\n\nfunc f() {}\n",
+		},
+		{
+			name: "empty",
+			lang: "go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(data.Doc{Id: "7", Lang: tt.lang, Text: tt.text})
+			for i := range tt.want {
+				tt.want[i].DocId = "7"
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}